@@ -0,0 +1,156 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// A bundle is a zip archive with one compressed entry per schema, named by the schema basename. The
+// comment of the archive is the Kubernetes version that the schemas were downloaded for.
+// The bundle can be embedded into the binary with `go build -tags bundle`, see bundle_embed.go. It is
+// used when a schema isn't found in the db, if it is for the Kubernetes version that is validated against.
+
+const BUNDLE_PATH = "bundle/kubernetes.zip"
+
+var (
+	bundleOnce   sync.Once
+	bundleReader *zip.Reader
+)
+
+func embeddedSchemas() *zip.Reader {
+	bundleOnce.Do(func() {
+		if len(embeddedBundle) == 0 {
+			return
+		}
+		r, err := zip.NewReader(bytes.NewReader(embeddedBundle), int64(len(embeddedBundle)))
+		if err != nil {
+			panicf("the embedded schema bundle is invalid: %s", err)
+		}
+		bundleReader = r
+	})
+	return bundleReader
+}
+
+func readBundledSchema(basename string) ([]byte, error) {
+	bundle := embeddedSchemas()
+	if bundle == nil {
+		return nil, ErrSchemaNotExist
+	}
+	f, err := bundle.Open(basename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrSchemaNotExist
+		}
+		return nil, fmt.Errorf("open %s in bundle: %s", basename, err)
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func bundledSchemaIds() []string {
+	bundle := embeddedSchemas()
+	if bundle == nil {
+		return nil
+	}
+	var ids []string
+	for _, f := range bundle.File {
+		ids = append(ids, f.Name)
+	}
+	return ids
+}
+
+func bundleVersion() string {
+	bundle := embeddedSchemas()
+	if bundle == nil {
+		return ""
+	}
+	return bundle.Comment
+}
+
+// Return true if the bundle has the schemas for `k8sVersion`. The bundle is also used when no version is
+// set, i.e. for `master`.
+func isBundleFor(k8sVersion string) bool {
+	version := bundleVersion()
+	return version != "" && (k8sVersion == DEFAULT_K8S_VERSION || strings.TrimPrefix(version, "v") == k8sVersion)
+}
+
+// The API groups that are served by Kubernetes itself, as opposed to by custom resource definitions
+var kubernetesCoreGroups = []string{
+	"",
+	"admissionregistration.k8s.io",
+	"apps",
+	"authentication.k8s.io",
+	"authorization.k8s.io",
+	"autoscaling",
+	"batch",
+	"certificates.k8s.io",
+	"coordination.k8s.io",
+	"discovery.k8s.io",
+	"events.k8s.io",
+	"extensions",
+	"flowcontrol.apiserver.k8s.io",
+	"networking.k8s.io",
+	"node.k8s.io",
+	"policy",
+	"rbac.authorization.k8s.io",
+	"resource.k8s.io",
+	"scheduling.k8s.io",
+	"storage.k8s.io",
+	"storagemigration.k8s.io",
+}
+
+// Write the core Kubernetes schemas in the db to a bundle at `output`
+func writeBundle(output, version string) error {
+	ids, err := dbSchemaIds()
+	if err != nil {
+		return fmt.Errorf("get schema ids: %s", err)
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	count := 0
+	for _, id := range ids {
+		if !slices.Contains(kubernetesCoreGroups, schemaIdToGvk(id).group) {
+			continue
+		}
+		schema, err := os.ReadFile(filepath.Join(schemaDir(), id))
+		if err != nil {
+			return fmt.Errorf("read schema: %s", err)
+		}
+		f, err := w.Create(id)
+		if err != nil {
+			return fmt.Errorf("add %s to bundle: %s", id, err)
+		}
+		if _, err := f.Write(schema); err != nil {
+			return fmt.Errorf("add %s to bundle: %s", id, err)
+		}
+		count++
+	}
+	if count == 0 {
+		return fmt.Errorf("no kubernetes schemas found in %s, run `yamlls refresh --k8s-version %s` first", schemaDir(), version)
+	}
+	if err := w.SetComment(version); err != nil {
+		return fmt.Errorf("set bundle version: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("write bundle: %s", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return fmt.Errorf("create dir for bundle: %s", err)
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("write bundle: %s", err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d schemas for kubernetes %s to %s\n", count, version, output)
+	if !strings.HasSuffix(filepath.ToSlash(output), BUNDLE_PATH) {
+		fmt.Fprintf(os.Stderr, "move it to %s to embed it with `go build -tags bundle`\n", BUNDLE_PATH)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build bundle
+
+package main
+
+import _ "embed"
+
+// Generate the bundle with `yamlls bundle` before building with `-tags bundle`
+//
+//go:embed bundle/kubernetes.zip
+var embeddedBundle []byte
@@ -0,0 +1,5 @@
+//go:build !bundle
+
+package main
+
+var embeddedBundle []byte
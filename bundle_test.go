@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestWriteBundle(t *testing.T) {
+	dbDir, k8sVersion := DB_DIR, K8S_VERSION
+	DB_DIR = t.TempDir()
+	setKubernetesVersion("1.30.0")
+	t.Cleanup(func() {
+		DB_DIR = dbDir
+		setKubernetesVersion(k8sVersion)
+		embeddedBundle = nil
+		bundleOnce = sync.Once{}
+		bundleReader = nil
+	})
+	schemas := map[string]string{
+		"Service_v1.json":                       `{"description":"service"}`,
+		"Ingress_networking.k8s.io_v1.json":     `{"description":"ingress"}`,
+		"Application_argoproj.io_v1alpha1.json": `{"description":"application"}`,
+	}
+	if err := os.MkdirAll(schemaDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for basename, schema := range schemas {
+		if err := os.WriteFile(filepath.Join(schemaDir(), basename), []byte(schema), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	output := filepath.Join(t.TempDir(), "kubernetes.zip")
+	if err := writeBundle(output, "1.30.0"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	bundle, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embeddedBundle = bundle
+
+	if version := bundleVersion(); version != "1.30.0" {
+		t.Fatalf("expected version `1.30.0`, got `%s`", version)
+	}
+	ids := bundledSchemaIds()
+	slices.Sort(ids)
+	expected := []string{"Ingress_networking.k8s.io_v1.json", "Service_v1.json"}
+	if !slices.Equal(ids, expected) {
+		t.Fatalf("expected ids %v, got %v", expected, ids)
+	}
+	schema, err := readBundledSchema("Service_v1.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(schema) != schemas["Service_v1.json"] {
+		t.Fatalf("expected `%s`, got `%s`", schemas["Service_v1.json"], schema)
+	}
+	if _, err := readBundledSchema("Application_argoproj.io_v1alpha1.json"); !errors.Is(err, ErrSchemaNotExist) {
+		t.Fatalf("expected custom resources to be left out of the bundle, got %v", err)
+	}
+
+	// Without a db, the bundle is only used for its own version
+	DB_DIR = t.TempDir()
+	tests := map[string]struct {
+		k8sVersion string
+		found      bool
+	}{
+		"same-version":  {k8sVersion: "1.30.0", found: true},
+		"no-version":    {k8sVersion: "master", found: true},
+		"other-version": {k8sVersion: "1.29.0", found: false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			setKubernetesVersion(test.k8sVersion)
+			_, err := readSchema("Service_v1.json")
+			if test.found && err != nil {
+				t.Fatalf("expected the bundled schema, got %s", err)
+			}
+			if !test.found && !errors.Is(err, ErrSchemaNotExist) {
+				t.Fatalf("expected no schema, got %v", err)
+			}
+		})
+	}
+}
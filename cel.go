@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+)
+
+// The CEL rules in `x-kubernetes-validations`, e.g. `self.minReplicas <= self.maxReplicas` or
+// `self.all(c, c.name.startsWith('app-'))`, are evaluated with cel-go. Rules that don't compile, such as
+// transition rules that use `oldSelf` or functions that only Kubernetes has, are skipped.
+
+// The cost limit of a rule, like the API server has, so that a rule can't hang validation
+const CEL_COST_LIMIT = 1_000_000
+
+var (
+	celEnv = sync.OnceValues(func() (*cel.Env, error) {
+		return cel.NewEnv(cel.Variable("self", cel.DynType), ext.Strings(), ext.Sets(), ext.Lists())
+	})
+	// The same rules are evaluated for every document, compile them once
+	celProgramsMu sync.Mutex
+	celPrograms   = map[string]celCompiled{}
+)
+
+type celCompiled struct {
+	program cel.Program
+	err     error
+}
+
+// Compile a CEL rule where the value is `self`
+func celCompile(expression string) (cel.Program, error) {
+	celProgramsMu.Lock()
+	defer celProgramsMu.Unlock()
+	compiled, found := celPrograms[expression]
+	if !found {
+		compiled.program, compiled.err = celCompileUncached(expression)
+		celPrograms[expression] = compiled
+	}
+	return compiled.program, compiled.err
+}
+
+func celCompileUncached(expression string) (cel.Program, error) {
+	env, err := celEnv()
+	if err != nil {
+		return nil, fmt.Errorf("create cel environment: %s", err)
+	}
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, fmt.Errorf("compile `%s`: %s", expression, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("compile `%s`: the rule returns %s, not bool", expression, ast.OutputType())
+	}
+	program, err := env.Program(ast, cel.CostLimit(CEL_COST_LIMIT))
+	if err != nil {
+		return nil, fmt.Errorf("create program for `%s`: %s", expression, err)
+	}
+	return program, nil
+}
+
+// Evaluate a rule, return false if it evaluates to false and true if it evaluates to true or cannot be
+// evaluated, e.g. when it divides an int by a double. The API server wouldn't have accepted such a rule.
+func celRuleHolds(rule cel.Program, self any) bool {
+	result, _, err := rule.Eval(map[string]any{"self": self})
+	if err != nil {
+		return true
+	}
+	b, ok := result.Value().(bool)
+	return !ok || b
+}
+
+// Return `value` with the types that the API server gives it in CEL. Numbers are ints or doubles according
+// to the schema, or to how they are written if the schema doesn't say.
+func celValue(schema, value any) any {
+	s, _ := schema.(map[string]any)
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := s["properties"].(map[string]any)
+		result := make(map[string]any, len(v))
+		for key, child := range v {
+			if subSchema, found := properties[key]; found {
+				result[key] = celValue(subSchema, child)
+			} else {
+				result[key] = celValue(s["additionalProperties"], child)
+			}
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, child := range v {
+			result[i] = celValue(s["items"], child)
+		}
+		return result
+	case json.Number:
+		integer := s["type"] == "integer" || s["x-kubernetes-int-or-string"] == true
+		if s["type"] != "number" && (integer || !strings.ContainsAny(v.String(), ".eE")) {
+			if i, err := v.Int64(); err == nil {
+				return i
+			}
+		}
+		f, _ := v.Float64()
+		return f
+	default:
+		return value
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCelRules(t *testing.T) {
+	var schema, document any
+	if err := json.Unmarshal([]byte(`{"type": "object", "properties": {"ratio": {"type": "number"}}}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+	decoder := json.NewDecoder(strings.NewReader(`{
+  "replicas": 3,
+  "ratio": 3,
+  "scale": 1.5,
+  "name": "web-app",
+  "labels": {"app": "web"},
+  "containers": [{"name": "app"}, {"name": "sidecar"}]
+}`))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	self := celValue(schema, document)
+	tests := map[string]struct {
+		rule  string
+		holds bool
+	}{
+		"comparison":        {rule: "self.replicas > 2", holds: true},
+		"arithmetic":        {rule: "self.replicas * 2 + 1 == 7", holds: true},
+		"integer-division":  {rule: "self.replicas / 2 == 1", holds: true},
+		"double-by-schema":  {rule: "self.ratio / 2.0 == 1.5", holds: true},
+		"double-as-written": {rule: "self.scale * 2.0 == 3.0", holds: true},
+		"int-and-double":    {rule: "self.replicas / 2.0 == 7.0", holds: true},
+		"string-function":   {rule: "self.name.startsWith('web') && self.name.endsWith(\"app\")", holds: true},
+		"matches":           {rule: "self.name.matches('^[a-z]+$')", holds: false},
+		"size":              {rule: "size(self.name) <= 5", holds: false},
+		"has":               {rule: "has(self.labels.app) && !has(self.labels.tier)", holds: true},
+		"in":                {rule: "'app' in self.labels && 4 in [1, 2, 3]", holds: false},
+		"index":             {rule: "self.labels['app'] == 'web' && self.containers[1].name == 'sidecar'", holds: true},
+		"all":               {rule: "self.containers.all(c, c.name.size() > 3)", holds: false},
+		"exists-one":        {rule: "self.containers.exists_one(c, c.name == 'app')", holds: true},
+		"ternary":           {rule: "self.replicas > 1 ? has(self.labels) : true", holds: true},
+		"map-literal":       {rule: "self.labels == {'app': 'web'}", holds: true},
+		"error-is-decided":  {rule: "self.missing == 1 && self.replicas == 4", holds: false},
+		"missing-field":     {rule: "self.missing == 1", holds: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			rule, err := celCompile(test.rule)
+			if err != nil {
+				t.Fatalf("expected `%s` to compile, got %s", test.rule, err)
+			}
+			if holds := celRuleHolds(rule, self); holds != test.holds {
+				t.Fatalf("expected `%s` to be %v, got %v", test.rule, test.holds, holds)
+			}
+		})
+	}
+}
+
+func TestCelCompileUnsupported(t *testing.T) {
+	tests := map[string]string{
+		"transition-rule":     "self.replicas >= oldSelf.replicas",
+		"kubernetes-function": "isURL(self.url)",
+		"not-bool":            "self.replicas + 1",
+		"trailing-tokens":     "self.replicas 1",
+	}
+	for name, rule := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := celCompile(rule); err == nil {
+				t.Fatalf("expected `%s` not to compile", rule)
+			}
+		})
+	}
+}
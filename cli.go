@@ -0,0 +1,526 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+)
+
+// Set when building a release with `-ldflags "-X main.VERSION=v1.2.3"`
+var VERSION = ""
+
+type Command struct {
+	name, args, description string
+	// Define the flags of the command and return the function that runs it with the remaining arguments
+	setup func(flags *flag.FlagSet) func(args []string) error
+}
+
+// Initialized in init since `help` refers to it
+var commands []Command
+
+func init() {
+	commands = []Command{
+		{
+			name:        "serve",
+			args:        "[--stdio]",
+			description: "Run the language server, this is the default when no command is given",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				stdio := flags.Bool("stdio", true, "communicate over stdin and stdout, the only supported transport")
+				return func(args []string) error {
+					if !*stdio {
+						return fmt.Errorf("only --stdio is supported")
+					}
+					if err := runLanguageServer(); err != nil {
+						return fmt.Errorf("run language server: %s", err)
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name:        "validate",
+			args:        "[flags] <file, directory or ->...",
+			description: "Validate yaml files against their schemas",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				helm := flags.Bool("helm", false, "mask Go template actions, detected automatically for files in the templates dir of a Helm chart")
+				k8sVersion := flags.String("k8s-version", DEFAULT_K8S_VERSION, "the kubernetes version to validate against")
+				format := flags.String("format", OUTPUT_FORMAT_TEXT, fmt.Sprintf("the output format, one of %s", strings.Join(outputFormats, ", ")))
+				failOnFlag := flags.String("fail-on", "error", "exit with status 1 if there are errors with this severity or higher, error or warn")
+				jobs := flags.Int("jobs", runtime.NumCPU(), "the number of files to validate in parallel")
+				var include, exclude []string
+				flags.Func("include", "a glob for the files to validate in directories, can be repeated (default *.yaml and *.yml)", func(s string) error {
+					include = append(include, s)
+					return nil
+				})
+				flags.Func("exclude", "a glob for the files and directories to skip, can be repeated", func(s string) error {
+					exclude = append(exclude, s)
+					return nil
+				})
+				return func(args []string) error {
+					setKubernetesVersion(*k8sVersion)
+					failOn, err := parseSeverity(*failOnFlag)
+					if err != nil {
+						return err
+					}
+					if !slices.Contains(outputFormats, *format) {
+						return fmt.Errorf("unknown format `%s`, expected one of %s", *format, strings.Join(outputFormats, ", "))
+					}
+					if len(args) == 0 {
+						return fmt.Errorf("must provide the files or directories to validate, or - for stdin")
+					}
+					files, err := collectFiles(args, include, exclude)
+					if err != nil {
+						return fmt.Errorf("find files to validate: %s", err)
+					}
+					results := validateFiles(files, os.Stdin, *helm, *jobs)
+					if err := writeValidationErrors(os.Stdout, *format, results, failOn); err != nil {
+						return fmt.Errorf("write output: %s", err)
+					}
+					fmt.Fprintln(os.Stderr, validationSummary(results))
+					for _, result := range results {
+						for _, e := range result.Errors {
+							if e.Severity.atLeast(failOn) {
+								return ExitCodeError{Code: 1}
+							}
+						}
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name:        "schemas",
+			args:        "[flags]",
+			description: "List the available schemas",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				k8sVersion := flags.String("k8s-version", DEFAULT_K8S_VERSION, "the kubernetes version to list schemas for")
+				var filter SchemaFilter
+				flags.StringVar(&filter.query, "filter", "", "only list schemas that fuzzy match this, e.g. `deployapps`, the best matches first")
+				flags.StringVar(&filter.kind, "kind", "", "only list schemas for this kind")
+				flags.StringVar(&filter.group, "group", "", "only list schemas in this group, e.g. apps")
+				flags.StringVar(&filter.version, "version", "", "only list schemas with this version, e.g. v1")
+				return func(args []string) error {
+					setKubernetesVersion(*k8sVersion)
+					if err := listSchemas(filter); err != nil {
+						return fmt.Errorf("list schemas: %s", err)
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name:        "explain",
+			args:        "[flags] <schema> [path]",
+			description: "Describe the fields of a schema, like `kubectl explain`",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				k8sVersion := flags.String("k8s-version", DEFAULT_K8S_VERSION, "the kubernetes version of the schema")
+				recursive := flags.Bool("recursive", false, "show all nested fields as a tree, without descriptions")
+				return func(args []string) error {
+					setKubernetesVersion(*k8sVersion)
+					if len(args) == 0 {
+						return fmt.Errorf("must provide the schema, e.g. `yamlls explain Deployment_apps_v1 spec.template`")
+					}
+					path := ""
+					if len(args) > 1 {
+						path = args[1]
+					}
+					return explain(os.Stdout, args[0], path, *recursive)
+				}
+			},
+		},
+		{
+			name:        "docs",
+			args:        "[flags] <schema> [path]",
+			description: "Render the documentation for a schema and print where it is, with an anchor to path",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				k8sVersion := flags.String("k8s-version", DEFAULT_K8S_VERSION, "the kubernetes version of the schema")
+				format := flags.String("format", DOCS_FORMAT_HTML, "the format of the documentation, html or markdown")
+				serve := flags.Bool("serve", false, "serve the documentation for all schemas on 127.0.0.1 instead, the schema is optional")
+				port := flags.Int("port", 0, "the port to serve the documentation on, a random port if 0")
+				return func(args []string) error {
+					setKubernetesVersion(*k8sVersion)
+					if *serve {
+						listener, docsUrl, err := listenDocs(*port)
+						if err != nil {
+							return err
+						}
+						if len(args) > 0 {
+							basename, err := findSchemaId(args[0])
+							if err != nil {
+								return err
+							}
+							docsUrl += "/" + strings.TrimSuffix(basename, ".json")
+							if len(args) > 1 {
+								docsUrl += "#" + docsAnchor(args[1])
+							}
+						}
+						fmt.Println(docsUrl)
+						return http.Serve(listener, docsHandler())
+					}
+					if len(args) == 0 {
+						return fmt.Errorf("must provide the schema, e.g. `yamlls docs Deployment_apps_v1 spec.template`")
+					}
+					path, err := schemaDocs(args[0], *format)
+					if err != nil {
+						return fmt.Errorf("render docs: %s", err)
+					}
+					if len(args) > 1 {
+						path += "#" + docsAnchor(args[1])
+					}
+					fmt.Println(path)
+					return nil
+				}
+			},
+		},
+		{
+			name:        "schema",
+			args:        "[flags] show <schema> [path]",
+			description: "Print a schema, or the part of it at path, as json with references resolved",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				k8sVersion := flags.String("k8s-version", DEFAULT_K8S_VERSION, "the kubernetes version of the schema")
+				return func(args []string) error {
+					setKubernetesVersion(*k8sVersion)
+					if len(args) < 2 || args[0] != "show" {
+						return fmt.Errorf("expected `yamlls schema show <schema> [path]`")
+					}
+					path := ""
+					if len(args) > 2 {
+						path = args[2]
+					}
+					return showSchema(os.Stdout, args[1], path)
+				}
+			},
+		},
+		{
+			name:        "fill",
+			args:        "[flags] <schema> [path]",
+			description: "Print a document with values for a schema, or for the field at path",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				k8sVersion := flags.String("k8s-version", DEFAULT_K8S_VERSION, "the kubernetes version of the schema")
+				var options FillOptions
+				flags.BoolVar(&options.required, "required", false, "only fill the required properties, recursively")
+				flags.IntVar(&options.depth, "depth", 0, "the levels of properties to fill, defaults to 1 or unlimited with --required")
+				flags.BoolVar(&options.defaults, "defaults", false, "use the default, the first example or the first enum value instead of zero values")
+				return func(args []string) error {
+					setKubernetesVersion(*k8sVersion)
+					var basename string
+					path := "."
+					switch len(args) {
+					case 0:
+						return fmt.Errorf("must provide `basename`, e.g. `yamlls fill Deployment-apps-v1.json`. Get the basename from `yamlls schemas`.")
+					case 1:
+						basename = args[0]
+					default:
+						basename = args[0]
+						path = args[1]
+					}
+					schema, err := readSchema(basename)
+					if err != nil {
+						return fmt.Errorf("fill schema: read schema %s: %s", basename, err)
+					}
+					yamlDoc, err := schemaFill(schema, path, options)
+					if err != nil {
+						return fmt.Errorf("fill schema: %s", err)
+					}
+					fmt.Print(yamlDoc)
+					return nil
+				}
+			},
+		},
+		{
+			name:        "new",
+			args:        "[flags] <kind> [name]",
+			description: "Print a minimal manifest for a kind, like `kubectl create --dry-run=client -o yaml`",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				k8sVersion := flags.String("k8s-version", DEFAULT_K8S_VERSION, "the kubernetes version of the schema, for kinds without a scaffold")
+				return func(args []string) error {
+					setKubernetesVersion(*k8sVersion)
+					if len(args) == 0 {
+						return fmt.Errorf("must provide the kind, e.g. `yamlls new Deployment web`. There are manifests for %s, other kinds get the required fields from the schema", strings.Join(scaffoldKinds(), ", "))
+					}
+					name := ""
+					if len(args) > 1 {
+						name = args[1]
+					}
+					snippet, err := scaffoldSnippet(args[0])
+					if err != nil {
+						return fmt.Errorf("scaffold %s: %s", args[0], err)
+					}
+					fmt.Print(expandSnippet(snippet, name))
+					return nil
+				}
+			},
+		},
+		{
+			name:        "format",
+			args:        "[flags] <file|->...",
+			description: "Format yaml files, keeping comments. Prints the result unless --write is given",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				k8sVersion := flags.String("k8s-version", DEFAULT_K8S_VERSION, "the kubernetes version of the schemas used to sort keys")
+				indent := flags.Int("indent", 0, "the number of spaces to indent with, detected from each file if 0")
+				sortKeys := flags.Bool("sort-keys", false, "sort keys, apiVersion, kind, metadata, spec and data first")
+				write := flags.Bool("write", false, "write the result to the files instead of printing it")
+				return func(args []string) error {
+					setKubernetesVersion(*k8sVersion)
+					if len(args) == 0 {
+						return fmt.Errorf("must provide at least one file, or - for stdin")
+					}
+					options := FormatOptions{indent: *indent, sortKeys: *sortKeys}
+					for _, file := range args {
+						var b []byte
+						var err error
+						if file == STDIN_FILENAME {
+							b, err = io.ReadAll(os.Stdin)
+						} else {
+							b, err = os.ReadFile(file)
+						}
+						if err != nil {
+							return fmt.Errorf("read %s: %s", file, err)
+						}
+						formatted, err := formatFile(string(b), options)
+						if err != nil {
+							return fmt.Errorf("format %s: %s", file, err)
+						}
+						if !*write || file == STDIN_FILENAME {
+							fmt.Print(formatted)
+							continue
+						}
+						if formatted == string(b) {
+							continue
+						}
+						if err := os.WriteFile(file, []byte(formatted), 0644); err != nil {
+							return fmt.Errorf("write %s: %s", file, err)
+						}
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name:        "refresh",
+			args:        "[flags]",
+			description: "Download the schemas into the database",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				k8sVersion := flags.String("k8s-version", DEFAULT_K8S_VERSION, "the kubernetes version to download schemas for, e.g. 1.29.0")
+				sourcesFile := flags.String("sources", "", fmt.Sprintf("the file with the sources to download schemas from, defaults to %s", filepath.Join(CONFIG_DIR, SOURCES_FILENAME)))
+				var sourceFlags []SchemaSource
+				flags.Func("source", "a source to download schemas from, as `<kind>=<url>`. Can be repeated, overrides --sources", func(s string) error {
+					source, err := parseSchemaSource(s)
+					sourceFlags = append(sourceFlags, source)
+					return err
+				})
+				return func(args []string) error {
+					setKubernetesVersion(*k8sVersion)
+					sources := sourceFlags
+					if len(sources) == 0 {
+						var err error
+						if sources, err = readSchemaSources(*sourcesFile); err != nil {
+							return fmt.Errorf("refresh database: %s", err)
+						}
+					}
+					if err := refreshDatabase(sources); err != nil {
+						return fmt.Errorf("refresh database: %s", err)
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name:        "import-crd",
+			args:        "[flags] <file>...",
+			description: "Import the schemas from custom resource definitions into the database",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				k8sVersion := flags.String("k8s-version", DEFAULT_K8S_VERSION, "the kubernetes version to import the schemas for")
+				return func(args []string) error {
+					setKubernetesVersion(*k8sVersion)
+					if len(args) == 0 {
+						return fmt.Errorf("must provide the files with custom resource definitions to import")
+					}
+					for _, file := range args {
+						contents, err := os.ReadFile(file)
+						if err != nil {
+							return fmt.Errorf("read `%s`: %s", file, err)
+						}
+						absPath, err := filepath.Abs(file)
+						if err != nil {
+							return fmt.Errorf("get absolute path of `%s`: %s", file, err)
+						}
+						basenames, err := importCrds(string(contents), "file://"+absPath)
+						if err != nil {
+							return fmt.Errorf("import custom resource definitions from `%s`: %s", file, err)
+						}
+						for _, basename := range basenames {
+							fmt.Println(basename)
+						}
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name:        "import-openapi",
+			args:        "[flags] <file or url>...",
+			description: "Import the schemas that a cluster serves at /openapi/v3 or /openapi/v2 into the database",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				k8sVersion := flags.String("k8s-version", DEFAULT_K8S_VERSION, "the kubernetes version to import the schemas for")
+				return func(args []string) error {
+					setKubernetesVersion(*k8sVersion)
+					if len(args) == 0 {
+						return fmt.Errorf("must provide a file or url with the /openapi/v3 or /openapi/v2 document, e.g. from `kubectl get --raw /openapi/v2`")
+					}
+					for _, source := range args {
+						basenames, err := importOpenApi(source)
+						if err != nil {
+							return fmt.Errorf("import openapi document from `%s`: %s", source, err)
+						}
+						fmt.Fprintf(os.Stderr, "imported %d schemas from %s\n", len(basenames), source)
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name:        "bundle",
+			args:        "[flags]",
+			description: "Write the core kubernetes schemas in the database to a bundle that can be embedded in the binary",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				output := flags.String("o", BUNDLE_PATH, "where to write the bundle")
+				k8sVersion := flags.String("k8s-version", DEFAULT_K8S_VERSION, "the kubernetes version to bundle schemas for")
+				return func(args []string) error {
+					setKubernetesVersion(*k8sVersion)
+					if err := writeBundle(*output, K8S_VERSION); err != nil {
+						return fmt.Errorf("bundle schemas: %s", err)
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name:        "help",
+			args:        "[command]",
+			description: "Show the available commands, or the flags of a command",
+			setup: func(flags *flag.FlagSet) func([]string) error {
+				return func(args []string) error {
+					if len(args) == 0 {
+						printUsage(os.Stdout)
+						return nil
+					}
+					command, found := findCommand(args[0])
+					if !found {
+						return unknownCommandError(args[0])
+					}
+					commandFlags, _ := newCommandFlags(command)
+					commandFlags.SetOutput(os.Stdout)
+					commandFlags.Usage()
+					return nil
+				}
+			},
+		},
+	}
+}
+
+func run(args []string) error {
+	global := flag.NewFlagSet("yamlls", flag.ExitOnError)
+	global.Usage = func() { printUsage(global.Output()) }
+	version := global.Bool("version", false, "print the version")
+	addDirFlags(global)
+	global.Parse(args)
+	if *version {
+		fmt.Println(versionString())
+		return nil
+	}
+	args = global.Args()
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	command, found := findCommand(name)
+	if !found {
+		return unknownCommandError(name)
+	}
+	flags, runCommand := newCommandFlags(command)
+	flags.Parse(args)
+	if err := migrateFlatDb(); err != nil {
+		fmt.Fprintf(os.Stderr, "move the schemas to the db layout per kubernetes version: %s\n", err)
+	}
+	if err := restoreInterruptedSwap(); err != nil {
+		fmt.Fprintf(os.Stderr, "restore the schemas from an interrupted refresh: %s\n", err)
+	}
+	return runCommand(flags.Args())
+}
+
+func findCommand(name string) (Command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return Command{}, false
+}
+
+func unknownCommandError(name string) error {
+	return fmt.Errorf("unknown command `%s`, run `yamlls help` to see the available commands", name)
+}
+
+// Return the flags for `command`, including the flags for the directories that every command accepts, and
+// the function that runs it
+func newCommandFlags(command Command) (*flag.FlagSet, func([]string) error) {
+	flags := flag.NewFlagSet(command.name, flag.ExitOnError)
+	runCommand := command.setup(flags)
+	addDirFlags(flags)
+	flags.Usage = func() {
+		w := flags.Output()
+		fmt.Fprintf(w, "Usage: yamlls %s %s\n\n%s\n\nFlags:\n", command.name, command.args, command.description)
+		flags.PrintDefaults()
+	}
+	return flags, runCommand
+}
+
+// Set when --db-dir is given, so that --cache-dir doesn't override it
+var dbDirOverridden bool
+
+func addDirFlags(flags *flag.FlagSet) {
+	flags.Func("cache-dir", fmt.Sprintf("the `dir` for logs and other generated files (default %s)", CACHE_DIR), func(dir string) error {
+		CACHE_DIR = dir
+		if !dbDirOverridden {
+			DB_DIR = filepath.Join(dir, "db")
+		}
+		return nil
+	})
+	flags.Func("db-dir", fmt.Sprintf("the `dir` with the schemas for each kubernetes version (default %s)", DB_DIR), func(dir string) error {
+		DB_DIR = dir
+		dbDirOverridden = true
+		return nil
+	})
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: yamlls [--version] [--cache-dir <dir>] [--db-dir <dir>] <command> [flags] [args]\n\n")
+	fmt.Fprintf(w, "A language server for yaml files, with schemas for kubernetes resources.\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(w, "\nRun `yamlls help <command>` to see the flags of a command.\n")
+}
+
+func versionString() string {
+	version := VERSION
+	if version == "" {
+		version = "(devel)"
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+			version = info.Main.Version
+		}
+	}
+	s := "yamlls " + version
+	if v := bundleVersion(); v != "" {
+		s += fmt.Sprintf(", with bundled schemas for kubernetes %s", v)
+	}
+	return s
+}
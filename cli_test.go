@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDirFlags(t *testing.T) {
+	cacheDir, dbDir, overridden := CACHE_DIR, DB_DIR, dbDirOverridden
+	t.Cleanup(func() {
+		CACHE_DIR, DB_DIR, dbDirOverridden = cacheDir, dbDir, overridden
+	})
+	tests := map[string]struct {
+		args            []string
+		cacheDir, dbDir string
+	}{
+		"cache-dir": {
+			args:     []string{"--cache-dir", "/tmp/cache", "help"},
+			cacheDir: "/tmp/cache",
+			dbDir:    filepath.Join("/tmp/cache", "db"),
+		},
+		"db-dir-before-cache-dir": {
+			args:     []string{"--db-dir", "/tmp/db", "--cache-dir", "/tmp/cache", "help"},
+			cacheDir: "/tmp/cache",
+			dbDir:    "/tmp/db",
+		},
+		"command-flags": {
+			args:     []string{"schemas", "--db-dir", "/tmp/does-not-exist"},
+			cacheDir: cacheDir,
+			dbDir:    "/tmp/does-not-exist",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			CACHE_DIR, DB_DIR, dbDirOverridden = cacheDir, dbDir, false
+			stdout := os.Stdout
+			os.Stdout, _ = os.Open(os.DevNull)
+			err := run(test.args)
+			os.Stdout = stdout
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if CACHE_DIR != test.cacheDir || DB_DIR != test.dbDir {
+				t.Fatalf("expected cache dir %s and db dir %s, got %s and %s", test.cacheDir, test.dbDir, CACHE_DIR, DB_DIR)
+			}
+		})
+	}
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	if err := run([]string{"lint"}); err == nil || !strings.Contains(err.Error(), "unknown command `lint`") {
+		t.Fatalf("expected an error about the unknown command, got %v", err)
+	}
+	if err := run([]string{"help", "lint"}); err == nil {
+		t.Fatalf("expected an error about the unknown command")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	var b bytes.Buffer
+	printUsage(&b)
+	for _, c := range commands {
+		if !strings.Contains(b.String(), "\n  "+c.name+" ") {
+			t.Fatalf("expected %s to be listed in the usage, got\n%s", c.name, b.String())
+		}
+	}
+	for _, c := range commands {
+		flags, _ := newCommandFlags(c)
+		var b bytes.Buffer
+		flags.SetOutput(&b)
+		flags.Usage()
+		if !strings.HasPrefix(b.String(), "Usage: yamlls "+c.name) || !strings.Contains(b.String(), "-db-dir") {
+			t.Fatalf("expected the usage of %s to include the dir flags, got\n%s", c.name, b.String())
+		}
+	}
+}
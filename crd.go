@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Return the schemas for the served versions in a CustomResourceDefinition document, by basename
+func crdSchemas(doc string) (map[string][]byte, error) {
+	var crd struct {
+		Spec struct {
+			Group string `yaml:"group"`
+			Names struct {
+				Kind string `yaml:"kind"`
+			} `yaml:"names"`
+			Versions []struct {
+				Name   string `yaml:"name"`
+				Served *bool  `yaml:"served"`
+				Schema struct {
+					OpenAPIV3Schema map[string]any `yaml:"openAPIV3Schema"`
+				} `yaml:"schema"`
+			} `yaml:"versions"`
+		} `yaml:"spec"`
+	}
+	if err := yaml.Unmarshal([]byte(doc), &crd); err != nil {
+		return nil, fmt.Errorf("unmarshal custom resource definition: %s", err)
+	}
+	group, kind := crd.Spec.Group, crd.Spec.Names.Kind
+	if group == "" || kind == "" {
+		return nil, errors.New("expected .spec.group and .spec.names.kind to be set")
+	}
+	schemas := map[string][]byte{}
+	for _, v := range crd.Spec.Versions {
+		if v.Served != nil && !*v.Served || v.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		schema := convertCrdSchema(v.Schema.OpenAPIV3Schema).(map[string]any)
+		properties, _ := schema["properties"].(map[string]any)
+		if properties == nil {
+			properties = map[string]any{}
+			schema["properties"] = properties
+		}
+		apiVersion := group + "/" + v.Name
+		properties["apiVersion"] = map[string]any{"type": "string", "enum": []any{apiVersion}}
+		properties["kind"] = map[string]any{"type": "string", "enum": []any{kind}}
+		if _, found := properties["metadata"]; !found {
+			properties["metadata"] = map[string]any{"type": "object"}
+		}
+		schema["x-kubernetes-group-version-kind"] = []any{map[string]any{"group": group, "version": v.Name, "kind": kind}}
+		b, err := json.Marshal(schema)
+		if err != nil {
+			return nil, fmt.Errorf("marshal schema for version %s: %s", v.Name, err)
+		}
+		schemas[gvkToSchemaId(group, v.Name, kind)+".json"] = b
+	}
+	return schemas, nil
+}
+
+// Convert an openAPIV3Schema to a json schema like the ones in the db. Objects don't allow additional
+// properties unless they have `x-kubernetes-preserve-unknown-fields`, `nullable` is turned into a
+// `null` type and `x-kubernetes-int-or-string` into `oneOf` a string or an integer.
+func convertCrdSchema(node any) any {
+	switch n := node.(type) {
+	case map[string]any:
+		result := map[string]any{}
+		for key, value := range n {
+			switch key {
+			case "properties", "patternProperties", "definitions":
+				// The keys are property names, not keywords
+				if properties, ok := value.(map[string]any); ok {
+					converted := map[string]any{}
+					for name, subSchema := range properties {
+						converted[name] = convertCrdSchema(subSchema)
+					}
+					result[key] = converted
+					continue
+				}
+				result[key] = value
+			case "enum", "default", "example", "examples", "required", "x-kubernetes-list-map-keys", "x-kubernetes-validations":
+				result[key] = value
+			default:
+				result[key] = convertCrdSchema(value)
+			}
+		}
+		if _, hasAnyOf := n["anyOf"]; n["x-kubernetes-int-or-string"] == true && !hasAnyOf {
+			delete(result, "type")
+			result["oneOf"] = []any{map[string]any{"type": "string"}, map[string]any{"type": "integer"}}
+		}
+		if n["nullable"] == true {
+			if t, ok := result["type"].(string); ok {
+				result["type"] = []any{t, "null"}
+			}
+		}
+		if _, hasProperties := n["properties"]; hasProperties {
+			_, hasAdditional := n["additionalProperties"]
+			if !hasAdditional && n["x-kubernetes-preserve-unknown-fields"] != true {
+				result["additionalProperties"] = false
+			}
+		}
+		return result
+	case []any:
+		result := make([]any, len(n))
+		for i, e := range n {
+			result[i] = convertCrdSchema(e)
+		}
+		return result
+	default:
+		return node
+	}
+}
+
+// Write the schemas for all custom resource definitions in `file` to the db, return the basenames.
+// The schemas are marked as imported from `origin` in the manifest, so that they are kept when refreshing.
+func importCrds(file, origin string) ([]string, error) {
+	schemas := map[string][]byte{}
+	for _, doc := range documentsInFile(file) {
+		gvk, ok := documentGVK(doc.document)
+		if !ok || gvk.kind != "CustomResourceDefinition" || gvk.group != "apiextensions.k8s.io" {
+			continue
+		}
+		docSchemas, err := crdSchemas(doc.document)
+		if err != nil {
+			return nil, fmt.Errorf("document on line %d: %s", doc.start+1, err)
+		}
+		for basename, schema := range docSchemas {
+			schemas[basename] = schema
+		}
+	}
+	if len(schemas) == 0 {
+		return nil, errors.New("no custom resource definitions with schemas found")
+	}
+	return writeImportedSchemas(schemas, origin)
+}
+
+func writeImportedSchemas(schemas map[string][]byte, origin string) ([]string, error) {
+	dir := schemaDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create `%s`: %s", dir, err)
+	}
+	manifest := readManifest(dir)
+	var basenames []string
+	for basename, schema := range schemas {
+		if err := os.WriteFile(filepath.Join(dir, basename), schema, 0644); err != nil {
+			return nil, fmt.Errorf("write schema: %s", err)
+		}
+		manifest[basename] = ManifestEntry{Url: origin, Sha256: fileSha256(filepath.Join(dir, basename)), Imported: true}
+		schemaCacheMu.Lock()
+		delete(schemaCache, basename)
+		schemaCacheMu.Unlock()
+		basenames = append(basenames, basename)
+	}
+	if err := writeManifest(dir, manifest); err != nil {
+		return nil, err
+	}
+	return basenames, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const crontabCrd = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: crontabs.stable.example.com
+spec:
+  group: stable.example.com
+  names:
+    kind: CronTab
+    plural: crontabs
+  scope: Namespaced
+  versions:
+    - name: v1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              properties:
+                cronSpec:
+                  type: string
+                replicas:
+                  x-kubernetes-int-or-string: true
+                image:
+                  type: string
+                  nullable: true
+                extra:
+                  type: object
+                  x-kubernetes-preserve-unknown-fields: true
+    - name: v1beta1
+      served: false
+      storage: false
+      schema:
+        openAPIV3Schema:
+          type: object
+`
+
+func TestCrdSchemaDefinedTwice(t *testing.T) {
+	t.Cleanup(func() {
+		workspace.indexFile("a/crontab.yaml", "")
+		workspace.indexFile("b/crontab.yaml", "")
+	})
+	workspace.indexFile("a/crontab.yaml", crontabCrd)
+	expected, found := workspace.schema("CronTab_stable.example.com_v1.json")
+	if !found {
+		t.Fatalf("expected a schema")
+	}
+	workspace.indexFile("a/crontab.yaml", "")
+	workspace.indexFile("b/crontab.yaml", strings.Replace(crontabCrd, "cronSpec:", "schedule:", 1))
+	workspace.indexFile("a/crontab.yaml", crontabCrd)
+	for range 10 {
+		if schema, _ := workspace.schema("CronTab_stable.example.com_v1.json"); string(schema) != string(expected) {
+			t.Fatalf("expected the schema from the first file, got %s", schema)
+		}
+	}
+}
+
+func TestCrdSchemas(t *testing.T) {
+	t.Cleanup(func() { workspace.indexFile("crds/crontab.yaml", "") })
+	if !workspace.indexFile("crds/crontab.yaml", crontabCrd) {
+		t.Fatalf("expected the schemas to change")
+	}
+	if _, found := workspace.schema("CronTab_stable.example.com_v1beta1.json"); found {
+		t.Fatalf("expected no schema for a version that is not served")
+	}
+	tests := map[string]struct {
+		contents string
+		errors   []ValidationError
+	}{
+		"valid": {
+			contents: `apiVersion: stable.example.com/v1
+kind: CronTab
+metadata:
+  name: my-crontab
+spec:
+  cronSpec: "* * * * */5"
+  replicas: 50%
+  image: null
+  extra:
+    anything: goes
+`,
+			errors: nil,
+		},
+		"int-or-string": {
+			contents: `apiVersion: stable.example.com/v1
+kind: CronTab
+spec:
+  replicas: 3
+`,
+			errors: nil,
+		},
+		"additional-property": {
+			contents: `apiVersion: stable.example.com/v1
+kind: CronTab
+spec:
+  cronspec: "* * * * */5"
+`,
+			errors: []ValidationError{
+				{
+					Range: newRange(3, 2, 3, 10),
+					Type:  "additional_property_not_allowed",
+				},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			errors, fail := fileValidate(test.contents)
+			if fail != VALIDATION_FAILURE_REASON_NOT_A_FAILURE {
+				t.Fatalf("expected validation to work, got %s", fail)
+			}
+			if len(errors) != len(test.errors) {
+				t.Fatalf("expected %d errors, got %v", len(test.errors), errors)
+			}
+			for i := range errors {
+				if errors[i].Type != test.errors[i].Type {
+					t.Fatalf("expected type `%s`, got `%s`", test.errors[i].Type, errors[i].Type)
+				}
+				if errors[i].Range != test.errors[i].Range {
+					t.Fatalf("expected range %v, got %v", test.errors[i].Range, errors[i].Range)
+				}
+			}
+		})
+	}
+}
+
+func TestImportCrds(t *testing.T) {
+	dbDir := DB_DIR
+	DB_DIR = t.TempDir()
+	t.Cleanup(func() { DB_DIR = dbDir })
+	basenames, err := importCrds(crontabCrd, "file:///crontab.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(basenames) != 1 || basenames[0] != "CronTab_stable.example.com_v1.json" {
+		t.Fatalf("expected one schema for CronTab v1, got %v", basenames)
+	}
+	if _, err := os.Stat(filepath.Join(schemaDir(), basenames[0])); err != nil {
+		t.Fatalf("expected the schema to be written to the db: %s", err)
+	}
+	if entry := readManifest(schemaDir())[basenames[0]]; !entry.Imported {
+		t.Fatalf("expected the schema to be marked as imported, got %v", entry)
+	}
+	if _, err := importCrds("kind: Service\napiVersion: v1\n", ""); err == nil {
+		t.Fatalf("expected an error when there are no custom resource definitions")
+	}
+}
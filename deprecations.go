@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// An API version of a kind that has been deprecated and removed from Kubernetes.
+// See https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+type ApiDeprecation struct {
+	apiVersion, kind string
+	deprecatedIn     string // The kubernetes release where it was deprecated, e.g. `1.14`
+	removedIn        string // The kubernetes release where it is no longer served, e.g. `1.22`
+	replacement      string // The apiVersion to use instead, empty if there is none
+	replacementIn    string // The kubernetes release where the replacement is first served
+}
+
+var apiDeprecations = []ApiDeprecation{
+	{"extensions/v1beta1", "Deployment", "1.9", "1.16", "apps/v1", "1.9"},
+	{"extensions/v1beta1", "DaemonSet", "1.9", "1.16", "apps/v1", "1.9"},
+	{"extensions/v1beta1", "ReplicaSet", "1.9", "1.16", "apps/v1", "1.9"},
+	{"extensions/v1beta1", "NetworkPolicy", "1.9", "1.16", "networking.k8s.io/v1", "1.8"},
+	{"extensions/v1beta1", "PodSecurityPolicy", "1.11", "1.16", "policy/v1beta1", "1.10"},
+	{"apps/v1beta1", "Deployment", "1.9", "1.16", "apps/v1", "1.9"},
+	{"apps/v1beta1", "StatefulSet", "1.9", "1.16", "apps/v1", "1.9"},
+	{"apps/v1beta2", "Deployment", "1.9", "1.16", "apps/v1", "1.9"},
+	{"apps/v1beta2", "StatefulSet", "1.9", "1.16", "apps/v1", "1.9"},
+	{"apps/v1beta2", "DaemonSet", "1.9", "1.16", "apps/v1", "1.9"},
+	{"apps/v1beta2", "ReplicaSet", "1.9", "1.16", "apps/v1", "1.9"},
+	{"extensions/v1beta1", "Ingress", "1.14", "1.22", "networking.k8s.io/v1", "1.19"},
+	{"networking.k8s.io/v1beta1", "Ingress", "1.19", "1.22", "networking.k8s.io/v1", "1.19"},
+	{"networking.k8s.io/v1beta1", "IngressClass", "1.19", "1.22", "networking.k8s.io/v1", "1.19"},
+	{"admissionregistration.k8s.io/v1beta1", "MutatingWebhookConfiguration", "1.16", "1.22", "admissionregistration.k8s.io/v1", "1.16"},
+	{"admissionregistration.k8s.io/v1beta1", "ValidatingWebhookConfiguration", "1.16", "1.22", "admissionregistration.k8s.io/v1", "1.16"},
+	{"apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", "1.16", "1.22", "apiextensions.k8s.io/v1", "1.16"},
+	{"apiregistration.k8s.io/v1beta1", "APIService", "1.19", "1.22", "apiregistration.k8s.io/v1", "1.10"},
+	{"authentication.k8s.io/v1beta1", "TokenReview", "1.19", "1.22", "authentication.k8s.io/v1", "1.6"},
+	{"authorization.k8s.io/v1beta1", "SubjectAccessReview", "1.19", "1.22", "authorization.k8s.io/v1", "1.6"},
+	{"authorization.k8s.io/v1beta1", "LocalSubjectAccessReview", "1.19", "1.22", "authorization.k8s.io/v1", "1.6"},
+	{"authorization.k8s.io/v1beta1", "SelfSubjectAccessReview", "1.19", "1.22", "authorization.k8s.io/v1", "1.6"},
+	{"certificates.k8s.io/v1beta1", "CertificateSigningRequest", "1.19", "1.22", "certificates.k8s.io/v1", "1.19"},
+	{"coordination.k8s.io/v1beta1", "Lease", "1.19", "1.22", "coordination.k8s.io/v1", "1.14"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRole", "1.17", "1.22", "rbac.authorization.k8s.io/v1", "1.8"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRoleBinding", "1.17", "1.22", "rbac.authorization.k8s.io/v1", "1.8"},
+	{"rbac.authorization.k8s.io/v1beta1", "Role", "1.17", "1.22", "rbac.authorization.k8s.io/v1", "1.8"},
+	{"rbac.authorization.k8s.io/v1beta1", "RoleBinding", "1.17", "1.22", "rbac.authorization.k8s.io/v1", "1.8"},
+	{"scheduling.k8s.io/v1beta1", "PriorityClass", "1.14", "1.22", "scheduling.k8s.io/v1", "1.14"},
+	{"storage.k8s.io/v1beta1", "CSIDriver", "1.19", "1.22", "storage.k8s.io/v1", "1.18"},
+	{"storage.k8s.io/v1beta1", "CSINode", "1.17", "1.22", "storage.k8s.io/v1", "1.17"},
+	{"storage.k8s.io/v1beta1", "StorageClass", "1.19", "1.22", "storage.k8s.io/v1", "1.6"},
+	{"storage.k8s.io/v1beta1", "VolumeAttachment", "1.19", "1.22", "storage.k8s.io/v1", "1.13"},
+	{"batch/v1beta1", "CronJob", "1.21", "1.25", "batch/v1", "1.21"},
+	{"discovery.k8s.io/v1beta1", "EndpointSlice", "1.21", "1.25", "discovery.k8s.io/v1", "1.21"},
+	{"events.k8s.io/v1beta1", "Event", "1.19", "1.25", "events.k8s.io/v1", "1.19"},
+	{"autoscaling/v2beta1", "HorizontalPodAutoscaler", "1.22", "1.25", "autoscaling/v2", "1.23"},
+	{"policy/v1beta1", "PodDisruptionBudget", "1.21", "1.25", "policy/v1", "1.21"},
+	{"policy/v1beta1", "PodSecurityPolicy", "1.21", "1.25", "", ""},
+	{"node.k8s.io/v1beta1", "RuntimeClass", "1.20", "1.25", "node.k8s.io/v1", "1.20"},
+	{"autoscaling/v2beta2", "HorizontalPodAutoscaler", "1.23", "1.26", "autoscaling/v2", "1.23"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "FlowSchema", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1", "1.29"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "PriorityLevelConfiguration", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1", "1.29"},
+	{"storage.k8s.io/v1beta1", "CSIStorageCapacity", "1.24", "1.27", "storage.k8s.io/v1", "1.24"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "FlowSchema", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1", "1.29"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "PriorityLevelConfiguration", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1", "1.29"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "FlowSchema", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1", "1.29"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "PriorityLevelConfiguration", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1", "1.29"},
+}
+
+func gvkApiVersion(gvk GVK) string {
+	if gvk.group == "" {
+		return gvk.version
+	}
+	return gvk.group + "/" + gvk.version
+}
+
+// Return the deprecation of `gvk` if it is deprecated or removed in `k8sVersion`
+func apiDeprecation(gvk GVK, k8sVersion string) (ApiDeprecation, bool) {
+	apiVersion := gvkApiVersion(gvk)
+	for _, d := range apiDeprecations {
+		if d.kind == gvk.kind && d.apiVersion == apiVersion && isKubernetesVersionAtLeast(k8sVersion, d.deprecatedIn) {
+			return d, true
+		}
+	}
+	return ApiDeprecation{}, false
+}
+
+// Return the replacement if `k8sVersion` serves it
+func (d ApiDeprecation) servedReplacement(k8sVersion string) (string, bool) {
+	if d.replacement == "" || !isKubernetesVersionAtLeast(k8sVersion, d.replacementIn) {
+		return "", false
+	}
+	return d.replacement, true
+}
+
+func (d ApiDeprecation) message(k8sVersion string) string {
+	var message string
+	if isKubernetesVersionAtLeast(k8sVersion, d.removedIn) {
+		message = fmt.Sprintf("%s %s was removed in kubernetes %s", d.kind, d.apiVersion, d.removedIn)
+	} else {
+		message = fmt.Sprintf("%s %s is deprecated and will be removed in kubernetes %s", d.kind, d.apiVersion, d.removedIn)
+	}
+	if d.replacement == "" {
+		return message + ", there is no replacement"
+	}
+	if replacement, served := d.servedReplacement(k8sVersion); served {
+		return message + ", use " + replacement
+	}
+	return fmt.Sprintf("%s, %s is served from kubernetes %s", message, d.replacement, d.replacementIn)
+}
+
+// Compare the major and minor version of `version` with `release`, e.g. `1.22`. `master` is newer than
+// all releases.
+func isKubernetesVersionAtLeast(version, release string) bool {
+	parse := func(v string) (int, int) {
+		split := strings.Split(strings.TrimPrefix(v, "v"), ".")
+		if len(split) < 2 {
+			return 0, 0
+		}
+		major, _ := strconv.Atoi(split[0])
+		minor, _ := strconv.Atoi(split[1])
+		return major, minor
+	}
+	if version == DEFAULT_K8S_VERSION {
+		return true
+	}
+	major, minor := parse(version)
+	releaseMajor, releaseMinor := parse(release)
+	return major > releaseMajor || (major == releaseMajor && minor >= releaseMinor)
+}
+
+// Return the range of the apiVersion value in the document
+func apiVersionValueRange(doc string) (Range, bool) {
+	for i, line := range strings.Split(doc, "\n") {
+		value, found := strings.CutPrefix(line, "apiVersion:")
+		if !found {
+			continue
+		}
+		trimmed := strings.TrimLeft(value, " ")
+		start := len(line) - len(trimmed)
+		if comment := strings.Index(trimmed, " #"); comment != -1 {
+			trimmed = trimmed[:comment]
+		}
+		trimmed = strings.TrimRight(trimmed, " ")
+		return newRange(i, start, i, start+len(trimmed)), true
+	}
+	return Range{}, false
+}
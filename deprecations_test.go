@@ -0,0 +1,189 @@
+package main
+
+import "testing"
+
+func TestDeprecatedApiVersion(t *testing.T) {
+	dbDir, k8sVersion := DB_DIR, K8S_VERSION
+	DB_DIR = t.TempDir()
+	t.Cleanup(func() {
+		DB_DIR = dbDir
+		setKubernetesVersion(k8sVersion)
+	})
+	tests := map[string]struct {
+		contents, k8sVersion string
+		errors               []ValidationError
+	}{
+		"removed": {
+			contents: `kind: Ingress
+apiVersion: extensions/v1beta1 # old
+`,
+			k8sVersion: "1.29.0",
+			errors: []ValidationError{
+				{
+					Range:    newRange(1, 12, 1, 30),
+					Message:  "Ingress extensions/v1beta1 was removed in kubernetes 1.22, use networking.k8s.io/v1",
+					Type:     "deprecated_api_version",
+					Severity: SEVERITY_WARN,
+				},
+			},
+		},
+		"deprecated": {
+			contents: `apiVersion: policy/v1beta1
+kind: PodSecurityPolicy
+`,
+			k8sVersion: "1.24.3",
+			errors: []ValidationError{
+				{
+					Range:    newRange(0, 12, 0, 26),
+					Message:  "PodSecurityPolicy policy/v1beta1 is deprecated and will be removed in kubernetes 1.25, there is no replacement",
+					Type:     "deprecated_api_version",
+					Severity: SEVERITY_WARN,
+				},
+			},
+		},
+		"replacement-not-served": {
+			contents: `kind: Ingress
+apiVersion: extensions/v1beta1
+`,
+			k8sVersion: "1.18.0",
+			errors: []ValidationError{
+				{
+					Range:    newRange(1, 12, 1, 30),
+					Message:  "Ingress extensions/v1beta1 is deprecated and will be removed in kubernetes 1.22, networking.k8s.io/v1 is served from kubernetes 1.19",
+					Type:     "deprecated_api_version",
+					Severity: SEVERITY_WARN,
+				},
+			},
+		},
+		"before-deprecation": {
+			contents: `kind: Ingress
+apiVersion: networking.k8s.io/v1beta1
+`,
+			k8sVersion: "1.18.0",
+			errors: []ValidationError{
+				{
+					Range:    newRange(0, 0, 0, 0),
+					Message:  "no schema found for Ingress networking.k8s.io/v1beta1 in kubernetes 1.18.0",
+					Type:     "no_schema_found",
+					Severity: SEVERITY_WARN,
+					SchemaId: "Ingress_networking.k8s.io_v1beta1",
+				},
+			},
+		},
+		"second-document": {
+			contents: `kind: Namespace
+apiVersion: does-not-exist
+---
+kind: CronJob
+apiVersion: batch/v1beta1
+`,
+			k8sVersion: "master",
+			errors: []ValidationError{
+				{
+					Range:    newRange(0, 0, 0, 0),
+					Message:  "no schema found for Namespace does-not-exist in kubernetes master",
+					Type:     "no_schema_found",
+					Severity: SEVERITY_WARN,
+					SchemaId: "Namespace_does-not-exist",
+				},
+				{
+					Range:    newRange(4, 12, 4, 25),
+					Message:  "CronJob batch/v1beta1 was removed in kubernetes 1.25, use batch/v1",
+					Type:     "deprecated_api_version",
+					Severity: SEVERITY_WARN,
+				},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			setKubernetesVersion(test.k8sVersion)
+			errors, fail := fileValidate(test.contents)
+			if fail != VALIDATION_FAILURE_REASON_NOT_A_FAILURE {
+				t.Fatalf("expected validation to work, got %s", fail)
+			}
+			if len(errors) != len(test.errors) {
+				t.Fatalf("expected %d errors, got %v", len(test.errors), errors)
+			}
+			for i := range errors {
+				if errors[i] != test.errors[i] {
+					t.Fatalf("expected %v, got %v", test.errors[i], errors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestApiDeprecation(t *testing.T) {
+	tests := map[string]struct {
+		gvk         GVK
+		k8sVersion  string
+		deprecated  bool
+		replacement string
+	}{
+		"before-deprecation": {
+			gvk:        GVK{group: "networking.k8s.io", version: "v1beta1", kind: "Ingress"},
+			k8sVersion: "1.18.0",
+			deprecated: false,
+		},
+		"replacement-not-served": {
+			gvk:        GVK{group: "extensions", version: "v1beta1", kind: "Ingress"},
+			k8sVersion: "1.18.0",
+			deprecated: true,
+		},
+		"replacement-served": {
+			gvk:         GVK{group: "extensions", version: "v1beta1", kind: "Ingress"},
+			k8sVersion:  "1.19.0",
+			deprecated:  true,
+			replacement: "networking.k8s.io/v1",
+		},
+		"removed": {
+			gvk:         GVK{group: "batch", version: "v1beta1", kind: "CronJob"},
+			k8sVersion:  "master",
+			deprecated:  true,
+			replacement: "batch/v1",
+		},
+		"no-replacement": {
+			gvk:        GVK{group: "policy", version: "v1beta1", kind: "PodSecurityPolicy"},
+			k8sVersion: "1.24.0",
+			deprecated: true,
+		},
+		"not-deprecated": {
+			gvk:        GVK{group: "apps", version: "v1", kind: "Deployment"},
+			k8sVersion: "1.30.0",
+			deprecated: false,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			deprecation, deprecated := apiDeprecation(test.gvk, test.k8sVersion)
+			if deprecated != test.deprecated {
+				t.Fatalf("expected deprecated to be %v, got %v", test.deprecated, deprecated)
+			}
+			replacement, _ := deprecation.servedReplacement(test.k8sVersion)
+			if replacement != test.replacement {
+				t.Fatalf("expected replacement `%s`, got `%s`", test.replacement, replacement)
+			}
+		})
+	}
+}
+
+func TestIsKubernetesVersionAtLeast(t *testing.T) {
+	tests := map[string]struct {
+		version, release string
+		expected         bool
+	}{
+		"older":        {version: "1.21.3", release: "1.22", expected: false},
+		"same":         {version: "1.22.0", release: "1.22", expected: true},
+		"newer":        {version: "v1.30.1", release: "1.22", expected: true},
+		"master":       {version: "master", release: "1.32", expected: true},
+		"minor-digits": {version: "1.9.0", release: "1.16", expected: false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if actual := isKubernetesVersionAtLeast(test.version, test.release); actual != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	DOCS_FORMAT_HTML     = "html"
+	DOCS_FORMAT_MARKDOWN = "markdown"
+)
+
+// The maximum depth of nested fields in the documentation, recursive definitions are already cut off when
+// resolving references
+const MAX_DOCS_DEPTH = 32
+
+// Return the path to the documentation for schema `id`, rendering it if it isn't cached or the schema has
+// changed since. The documentation is cached in CACHE_DIR/docs/<kubernetes version>/<basename>.<ext>.
+func schemaDocs(id, format string) (string, error) {
+	if format != DOCS_FORMAT_HTML && format != DOCS_FORMAT_MARKDOWN {
+		return "", fmt.Errorf("unknown format `%s`, expected html or markdown", format)
+	}
+	schema, basename, err := readResolvedSchema(id)
+	if err != nil {
+		return "", err
+	}
+	raw, err := readSchema(basename)
+	if err != nil {
+		return "", fmt.Errorf("read schema %s: %s", basename, err)
+	}
+	sum := sha256.Sum256(raw)
+	hash := hex.EncodeToString(sum[:])
+
+	ext := ".html"
+	if format == DOCS_FORMAT_MARKDOWN {
+		ext = ".md"
+	}
+	path := filepath.Join(CACHE_DIR, "docs", K8S_VERSION, strings.TrimSuffix(basename, ".json")+ext)
+	// The hash of the schema the page was rendered from is in a file next to it, so that the page starts
+	// with the doctype
+	hashPath := path + ".sha256"
+	if cached, err := os.ReadFile(hashPath); err == nil && string(cached) == hash {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	var page string
+	if format == DOCS_FORMAT_HTML {
+		page = renderHtmlDocs(basename, schema, "")
+	} else {
+		page = renderMarkdownDocs(basename, schema)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("create docs dir: %s", err)
+	}
+	if err := os.WriteFile(path, []byte(page), 0644); err != nil {
+		return "", fmt.Errorf("write docs: %s", err)
+	}
+	if err := os.WriteFile(hashPath, []byte(hash), 0644); err != nil {
+		return "", fmt.Errorf("write docs hash: %s", err)
+	}
+	return path, nil
+}
+
+// Return the anchor of the field at `path` in the documentation. Array indexes are dropped,
+// `.spec.ports.0.name` becomes `spec.ports.name`.
+func docsAnchor(path string) string {
+	var segments []string
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '.' }) {
+		if strings.IndexFunc(segment, func(r rune) bool { return !unicode.IsDigit(r) }) == -1 {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	return strings.Join(segments, ".")
+}
+
+const docsStyle = `body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; line-height: 1.4; }
+details { margin-left: 1.5em; border-left: 1px solid #ddd; padding-left: 0.5em; }
+summary { cursor: pointer; }
+.name { font-family: monospace; font-weight: bold; }
+.type { font-family: monospace; color: #0a6; }
+.required { color: #c00; font-size: 0.85em; }
+.description { white-space: pre-wrap; color: #333; margin: 0.25em 0 0.5em 0; }
+.enum { font-family: monospace; font-size: 0.9em; }
+a.anchor { color: #999; text-decoration: none; margin-left: 0.25em; }
+:target > summary { background: #ffa; }`
+
+// Open the fields that lead to the field in the url, so that links to nested fields work
+const docsScript = `function openTarget() {
+  const target = document.getElementById(decodeURIComponent(location.hash.slice(1)));
+  for (let e = target; e; e = e.parentElement) { if (e.tagName === "DETAILS") e.open = true; }
+  if (target) target.scrollIntoView();
+}
+function setAll(open) { document.querySelectorAll("details").forEach((d) => d.open = open); }
+window.addEventListener("hashchange", openTarget);
+window.addEventListener("DOMContentLoaded", openTarget);`
+
+// Render the documentation as a web page, with a link to `indexUrl` if it is not empty
+func renderHtmlDocs(basename string, schema map[string]any, indexUrl string) string {
+	gvk := schemaIdToGvk(basename)
+	var b strings.Builder
+	title := html.EscapeString(fmt.Sprintf("%s %s", gvk.kind, gvkApiVersion(gvk)))
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s\n</style>\n<script>\n%s\n</script>\n</head>\n<body>\n", title, docsStyle, docsScript)
+	if indexUrl != "" {
+		fmt.Fprintf(&b, "<p><a href=\"%s\">All schemas</a></p>\n", html.EscapeString(indexUrl))
+	}
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", title)
+	if description, _ := schema["description"].(string); description != "" {
+		fmt.Fprintf(&b, "<div class=\"description\">%s</div>\n", html.EscapeString(description))
+	}
+	b.WriteString("<p><button onclick=\"setAll(true)\">Expand all</button> <button onclick=\"setAll(false)\">Collapse all</button></p>\n")
+	writeHtmlFields(&b, schema, "", 0)
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+func writeHtmlFields(b *strings.Builder, schema map[string]any, parent string, depth int) {
+	properties := schemaProperties(schema)
+	required := schemaRequired(schema)
+	for _, name := range slices.Sorted(maps.Keys(properties)) {
+		p := properties[name]
+		anchor := html.EscapeString(strings.TrimPrefix(parent+"."+name, "."))
+		open := ""
+		if depth == 0 {
+			open = " open"
+		}
+		fmt.Fprintf(b, "<details id=\"%s\"%s>\n<summary><span class=\"name\">%s</span> <span class=\"type\">%s</span>", anchor, open, html.EscapeString(name), html.EscapeString(schemaTypeName(p)))
+		if slices.Contains(required, name) {
+			b.WriteString(" <span class=\"required\">required</span>")
+		}
+		fmt.Fprintf(b, "<a class=\"anchor\" href=\"#%s\">#</a></summary>\n", anchor)
+		if description, _ := p["description"].(string); description != "" {
+			fmt.Fprintf(b, "<div class=\"description\">%s</div>\n", html.EscapeString(description))
+		}
+		if enum := enumValues(p); len(enum) > 0 {
+			fmt.Fprintf(b, "<div class=\"enum\">One of: %s</div>\n", html.EscapeString(strings.Join(enum, ", ")))
+		}
+		if depth < MAX_DOCS_DEPTH {
+			writeHtmlFields(b, p, anchor, depth+1)
+		}
+		b.WriteString("</details>\n")
+	}
+}
+
+func renderMarkdownDocs(basename string, schema map[string]any) string {
+	gvk := schemaIdToGvk(basename)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s %s\n\n", gvk.kind, gvkApiVersion(gvk))
+	if description, _ := schema["description"].(string); description != "" {
+		fmt.Fprintf(&b, "%s\n\n", description)
+	}
+	b.WriteString("## Fields\n\n")
+	writeMarkdownFields(&b, schema, "", 0)
+	return b.String()
+}
+
+func writeMarkdownFields(b *strings.Builder, schema map[string]any, parent string, depth int) {
+	properties := schemaProperties(schema)
+	required := schemaRequired(schema)
+	indent := strings.Repeat("  ", depth)
+	for _, name := range slices.Sorted(maps.Keys(properties)) {
+		p := properties[name]
+		anchor := strings.TrimPrefix(parent+"."+name, ".")
+		fmt.Fprintf(b, "%s- <a id=\"%s\"></a>**`%s`** `%s`", indent, anchor, name, schemaTypeName(p))
+		if slices.Contains(required, name) {
+			b.WriteString(" *required*")
+		}
+		b.WriteString("\n")
+		if description, _ := p["description"].(string); description != "" {
+			for _, line := range strings.Split(strings.TrimSpace(description), "\n") {
+				fmt.Fprintf(b, "%s  %s\n", indent, strings.TrimRightFunc(line, unicode.IsSpace))
+			}
+		}
+		if enum := enumValues(p); len(enum) > 0 {
+			fmt.Fprintf(b, "%s  One of: `%s`\n", indent, strings.Join(enum, "`, `"))
+		}
+		if depth < MAX_DOCS_DEPTH {
+			writeMarkdownFields(b, p, anchor, depth+1)
+		}
+	}
+}
+
+func enumValues(schema map[string]any) []string {
+	enum, _ := schema["enum"].([]any)
+	var values []string
+	for _, v := range enum {
+		values = append(values, fmt.Sprint(v))
+	}
+	return values
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// The url of the documentation server, e.g. `http://127.0.0.1:41234`. Empty if it isn't running.
+var DOCS_SERVER_URL = ""
+
+// Listen on 127.0.0.1 at `port`, a random port if it is 0. Serve the documentation with
+// `http.Serve(listener, docsHandler())`.
+func listenDocs(port int) (net.Listener, string, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, "", fmt.Errorf("listen on port %d: %s", port, err)
+	}
+	return listener, "http://" + listener.Addr().String(), nil
+}
+
+// Serve an index of all schemas at `/` and the documentation for a schema at `/<schema id>`, with
+// the same anchors as the static pages, e.g. `/Deployment_apps_v1#spec.template`
+func docsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", serveDocsIndex)
+	mux.HandleFunc("GET /{id}", serveSchemaDocs)
+	return mux
+}
+
+func serveSchemaDocs(w http.ResponseWriter, r *http.Request) {
+	schema, basename, err := readResolvedSchema(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, renderHtmlDocs(basename, schema, "/"))
+}
+
+func serveDocsIndex(w http.ResponseWriter, r *http.Request) {
+	ids, err := schemaIds()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get schema ids: %s", err), http.StatusInternalServerError)
+		return
+	}
+	query := r.URL.Query().Get("q")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, renderDocsIndex(filterSchemaIds(ids, SchemaFilter{query: query}), query))
+}
+
+// Render a list of the schemas grouped by api group, or ranked by how well they match `query` when
+// searching
+func renderDocsIndex(ids []string, query string) string {
+	var b strings.Builder
+	title := html.EscapeString("Schemas for Kubernetes " + K8S_VERSION)
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", title, docsStyle)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", title)
+	fmt.Fprintf(&b, "<form action=\"/\"><input type=\"search\" name=\"q\" value=\"%s\" placeholder=\"Search, e.g. deployapps\" autofocus></form>\n", html.EscapeString(query))
+	if query != "" {
+		if len(ids) == 0 {
+			fmt.Fprintf(&b, "<p>No schemas match <code>%s</code></p>\n", html.EscapeString(query))
+		}
+		b.WriteString("<ul>\n")
+		for _, id := range ids {
+			writeDocsIndexEntry(&b, id)
+		}
+		b.WriteString("</ul>\n</body>\n</html>\n")
+		return b.String()
+	}
+
+	groups := map[string][]string{}
+	for _, id := range ids {
+		group := schemaIdToGvk(id).group
+		if group == "" {
+			group = "core"
+		}
+		groups[group] = append(groups[group], id)
+	}
+	// The core group first, then alphabetically
+	names := slices.Sorted(maps.Keys(groups))
+	if i := slices.Index(names, "core"); i > 0 {
+		names = append([]string{"core"}, slices.Delete(names, i, i+1)...)
+	}
+	for _, name := range names {
+		ids := groups[name]
+		slices.SortFunc(ids, func(a, b string) int {
+			gvkA, gvkB := schemaIdToGvk(a), schemaIdToGvk(b)
+			return cmp.Or(strings.Compare(gvkA.kind, gvkB.kind), strings.Compare(gvkA.version, gvkB.version))
+		})
+		fmt.Fprintf(&b, "<details id=\"%s\" open>\n<summary><span class=\"name\">%s</span> <span class=\"type\">%d %s</span></summary>\n<ul>\n", html.EscapeString(name), html.EscapeString(name), len(ids), plural(len(ids), "schema"))
+		for _, id := range ids {
+			writeDocsIndexEntry(&b, id)
+		}
+		b.WriteString("</ul>\n</details>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+func writeDocsIndexEntry(b *strings.Builder, id string) {
+	gvk := schemaIdToGvk(id)
+	href := "/" + url.PathEscape(strings.TrimSuffix(id, ".json"))
+	fmt.Fprintf(b, "<li><a href=\"%s\">%s</a> <span class=\"type\">%s</span></li>\n", html.EscapeString(href), html.EscapeString(gvk.kind), html.EscapeString(gvkApiVersion(gvk)))
+}
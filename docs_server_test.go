@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDocsServer(t *testing.T) {
+	refs, err := os.ReadFile("testdata/refs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTestSchemas(t, map[string][]byte{
+		"Service_v1.json":            serviceV1,
+		"Person_example.com_v1.json": refs,
+	})
+	server := httptest.NewServer(docsHandler())
+	defer server.Close()
+
+	tests := map[string]struct {
+		path        string
+		status      int
+		contains    []string
+		notContains []string
+	}{
+		"index": {
+			path:   "/",
+			status: http.StatusOK,
+			contains: []string{
+				"<title>Schemas for Kubernetes 1.30.0</title>",
+				"<details id=\"core\" open>\n<summary><span class=\"name\">core</span> <span class=\"type\">1 schema</span></summary>\n<ul>\n<li><a href=\"/Service_v1\">Service</a> <span class=\"type\">v1</span></li>",
+				`<li><a href="/Person_example.com_v1">Person</a> <span class="type">example.com/v1</span></li>`,
+			},
+		},
+		"search": {
+			path:        "/?q=serv",
+			status:      http.StatusOK,
+			contains:    []string{`value="serv"`, `<a href="/Service_v1">Service</a>`},
+			notContains: []string{"Person", "<details"},
+		},
+		"no-matches": {
+			path:     "/?q=<zzz>",
+			status:   http.StatusOK,
+			contains: []string{"No schemas match <code>&lt;zzz&gt;</code>"},
+		},
+		"schema": {
+			path:   "/Service_v1",
+			status: http.StatusOK,
+			contains: []string{
+				`<p><a href="/">All schemas</a></p>`,
+				`<details id="spec.ports.port">`,
+			},
+		},
+		"kind": {
+			path:     "/service",
+			status:   http.StatusOK,
+			contains: []string{"<title>Service v1</title>"},
+		},
+		"unknown-schema": {
+			path:     "/Nope_v1",
+			status:   http.StatusNotFound,
+			contains: []string{"no schema found for `Nope_v1`"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, resp.StatusCode, body)
+			}
+			for _, c := range test.contains {
+				if !strings.Contains(string(body), c) {
+					t.Fatalf("expected the page to contain %q, got\n%s", c, body)
+				}
+			}
+			for _, c := range test.notContains {
+				if strings.Contains(string(body), c) {
+					t.Fatalf("expected the page to not contain %q, got\n%s", c, body)
+				}
+			}
+		})
+	}
+}
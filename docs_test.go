@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDocsAnchor(t *testing.T) {
+	tests := map[string]string{
+		".":                    "",
+		".spec.ports.0.name":   "spec.ports.name",
+		"spec.template.spec":   "spec.template.spec",
+		".spec.containers.12":  "spec.containers",
+		".metadata.labels.app": "metadata.labels.app",
+	}
+	for path, expected := range tests {
+		if actual := docsAnchor(path); actual != expected {
+			t.Fatalf("expected the anchor of %s to be %s, got %s", path, expected, actual)
+		}
+	}
+}
+
+func TestSchemaDocs(t *testing.T) {
+	useTestSchemas(t, map[string][]byte{"Service_v1.json": serviceV1})
+	cacheDir := CACHE_DIR
+	CACHE_DIR = t.TempDir()
+	t.Cleanup(func() { CACHE_DIR = cacheDir })
+
+	tests := map[string]struct {
+		format   string
+		contains []string
+	}{
+		"html": {
+			format: DOCS_FORMAT_HTML,
+			contains: []string{
+				"<title>Service v1</title>",
+				"<details id=\"spec.ports.port\">\n" + `<summary><span class="name">port</span> <span class="type">integer</span> <span class="required">required</span><a class="anchor" href="#spec.ports.port">#</a></summary>`,
+				`<div class="enum">One of: Service</div>`,
+			},
+		},
+		"markdown": {
+			format: DOCS_FORMAT_MARKDOWN,
+			contains: []string{
+				"# Service v1\n",
+				"    - <a id=\"spec.ports.port\"></a>**`port`** `integer` *required*\n      The port that will be exposed by this service.\n",
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path, err := schemaDocs("Service_v1", test.format)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			page, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range test.contains {
+				if !strings.Contains(string(page), c) {
+					t.Fatalf("expected the docs to contain\n%s", c)
+				}
+			}
+		})
+	}
+
+	path, err := schemaDocs("Service_v1", DOCS_FORMAT_HTML)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if page, _ := os.ReadFile(path); !strings.HasPrefix(string(page), "<!DOCTYPE html>") {
+		t.Fatalf("expected the page to start with the doctype")
+	}
+	if err := os.WriteFile(path, []byte("cached"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schemaDocs("Service_v1", DOCS_FORMAT_HTML); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if page, _ := os.ReadFile(path); string(page) != "cached" {
+		t.Fatalf("expected the cached docs to be used when the schema is unchanged")
+	}
+	if err := os.WriteFile(path+".sha256", []byte("outdated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schemaDocs("Service_v1", DOCS_FORMAT_HTML); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if page, _ := os.ReadFile(path); string(page) == "cached" {
+		t.Fatalf("expected the docs to be rendered again when the schema changed")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	yamlparser "github.com/goccy/go-yaml/parser"
+)
+
+type DuplicateKey struct {
+	Range     Range
+	Key       string
+	FirstLine int
+}
+
+// Return the keys that are defined more than once in the same mapping. Without duplicates, the document
+// is either valid or invalid for another reason.
+func duplicateKeys(doc string) []DuplicateKey {
+	astFile, err := yamlparser.ParseBytes([]byte(doc), 0, yamlparser.AllowDuplicateMapKey())
+	if err != nil {
+		return nil
+	}
+	var visitor duplicateKeyVisitor
+	for _, d := range astFile.Docs {
+		ast.Walk(&visitor, d)
+	}
+	return visitor.duplicates
+}
+
+type duplicateKeyVisitor struct{ duplicates []DuplicateKey }
+
+func (v *duplicateKeyVisitor) Visit(node ast.Node) ast.Visitor {
+	mapping, ok := node.(*ast.MappingNode)
+	if !ok {
+		return v
+	}
+	firstLines := map[string]int{}
+	for _, value := range mapping.Values {
+		t := value.Key.GetToken()
+		if t == nil || t.Value == "<<" {
+			continue
+		}
+		line := t.Position.Line - 1
+		if firstLine, found := firstLines[t.Value]; found {
+			v.duplicates = append(v.duplicates, DuplicateKey{
+				Range:     newRange(line, t.Position.Column-1, line, t.Position.Column-1+len(t.Value)),
+				Key:       t.Value,
+				FirstLine: firstLine,
+			})
+			continue
+		}
+		firstLines[t.Value] = line
+	}
+	return v
+}
+
+// A resource is identified by its group, kind, namespace and name. The version is not included, a
+// Deployment in apps/v1 and apps/v1beta1 is the same Deployment.
+type Resource struct {
+	group, kind, namespace, name string
+	// The range of `name` in `metadata`, relative to the file
+	Range Range
+}
+
+func (r Resource) identity() string {
+	return fmt.Sprintf("%s/%s/%s/%s", r.group, r.kind, r.namespace, r.name)
+}
+
+func (r Resource) String() string {
+	if r.namespace == "" {
+		return fmt.Sprintf("%s %s", r.kind, r.name)
+	}
+	return fmt.Sprintf("%s %s/%s", r.kind, r.namespace, r.name)
+}
+
+// Return the resource defined by a document, if it has a kind and a name
+func documentResource(doc DocumentPosition) (Resource, bool) {
+	gvk, ok := documentGVK(doc.document)
+	if !ok || gvk.kind == "" {
+		return Resource{}, false
+	}
+	var object struct {
+		Metadata struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+	}
+	if err := yaml.Unmarshal([]byte(doc.document), &object); err != nil || object.Metadata.Name == "" {
+		return Resource{}, false
+	}
+	range_ := documentPaths(doc.document)[".metadata.name"]
+	return Resource{
+		group:     gvk.group,
+		kind:      gvk.kind,
+		namespace: object.Metadata.Namespace,
+		name:      object.Metadata.Name,
+		Range:     newRange(doc.start+range_.Start.Line, range_.Start.Char, doc.start+range_.End.Line, range_.End.Char),
+	}, true
+}
+
+// Return the resources defined in a file
+func fileResources(file string) []Resource {
+	var resources []Resource
+	for _, doc := range documentsInFile(file) {
+		if len(duplicateKeys(doc.document)) > 0 {
+			continue
+		}
+		if resource, ok := documentResource(doc); ok {
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
+
+// Kustomize bases and overlays, and the patches in them, define the same resources on purpose
+func isKustomization(filename string) bool {
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		if _, err := os.Stat(filepath.Join(filepath.Dir(filename), name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDuplicateKeys(t *testing.T) {
+	tests := map[string]struct {
+		doc        string
+		duplicates []DuplicateKey
+	}{
+		"none": {
+			doc: `metadata:
+  name: a
+spec:
+  name: a
+`,
+		},
+		"top-level": {
+			doc: `kind: Service
+metadata: {}
+kind: Service
+`,
+			duplicates: []DuplicateKey{{Range: newRange(2, 0, 2, 4), Key: "kind", FirstLine: 0}},
+		},
+		"nested-in-list": {
+			doc: `ports:
+  - port: 80
+    name: http
+    port: 8080
+`,
+			duplicates: []DuplicateKey{{Range: newRange(3, 4, 3, 8), Key: "port", FirstLine: 1}},
+		},
+		"invalid-yaml": {
+			doc: `a: [
+`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			duplicates := duplicateKeys(test.doc)
+			if len(duplicates) != len(test.duplicates) {
+				t.Fatalf("expected %v, got %v", test.duplicates, duplicates)
+			}
+			for i := range duplicates {
+				if duplicates[i] != test.duplicates[i] {
+					t.Fatalf("expected %v, got %v", test.duplicates[i], duplicates[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateDuplicates(t *testing.T) {
+	schemaCache["Service_v1.json"] = serviceV1
+	t.Cleanup(func() { delete(schemaCache, "Service_v1.json") })
+
+	tests := map[string]struct {
+		file   string
+		errors []ValidationError
+	}{
+		"different-namespaces": {
+			file: `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: other
+`,
+		},
+		"duplicate-resource": {
+			file: `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`,
+			errors: []ValidationError{
+				{Range: newRange(8, 2, 8, 6), Message: "duplicate resource Service web, it is also defined on line 4", Type: "duplicate_resource", Severity: SEVERITY_WARN},
+			},
+		},
+		"duplicate-key": {
+			file: `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  name: api
+`,
+			errors: []ValidationError{
+				{Range: newRange(4, 2, 4, 6), Message: "duplicate key `name`, it is first defined on line 4", Type: "duplicate_key", Severity: SEVERITY_ERROR},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			errors, fail := fileValidate(test.file)
+			if fail != VALIDATION_FAILURE_REASON_NOT_A_FAILURE {
+				t.Fatalf("expected validation to work, got %s", fail)
+			}
+			if len(errors) != len(test.errors) {
+				t.Fatalf("expected %d errors, got %v", len(test.errors), errors)
+			}
+			for i := range errors {
+				if errors[i] != test.errors[i] {
+					t.Fatalf("expected %v, got %v", test.errors[i], errors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWorkspaceDuplicateResources(t *testing.T) {
+	dir := t.TempDir()
+	service := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+	first, second := filepath.Join(dir, "service.yaml"), filepath.Join(dir, "copy.yaml")
+	t.Cleanup(func() {
+		workspace.indexFile(first, "")
+		workspace.indexFile(second, "")
+	})
+	if !workspace.indexFile(first, service) || !workspace.indexFile(second, "---\n"+service) {
+		t.Fatalf("expected the resources to change")
+	}
+	errors := workspace.duplicateResources(first)
+	if len(errors) != 1 || errors[0].Message != "duplicate resource Service web, it is also defined in copy.yaml:5" || errors[0].Range != newRange(3, 2, 3, 6) {
+		t.Fatalf("expected a duplicate in copy.yaml, got %v", errors)
+	}
+
+	nested := filepath.Join(dir, "apps", "service.yaml")
+	prod, staging := filepath.Join(dir, "clusters", "prod", "service.yaml"), filepath.Join(dir, "clusters", "staging", "service.yaml")
+	t.Cleanup(func() {
+		workspace.indexFile(nested, "")
+		workspace.indexFile(prod, "")
+		workspace.indexFile(staging, "")
+	})
+	workspace.indexFile(first, "")
+	workspace.indexFile(second, "")
+	workspace.indexFile(prod, service)
+	workspace.indexFile(staging, service)
+	if errors := workspace.duplicateResources(prod); len(errors) != 0 {
+		t.Fatalf("expected no duplicates between sibling directories, got %v", errors)
+	}
+	workspace.indexFile(first, service)
+	workspace.indexFile(nested, service)
+	errors = workspace.duplicateResources(nested)
+	if len(errors) != 1 || errors[0].Message != "duplicate resource Service web, it is also defined in ../service.yaml:4" {
+		t.Fatalf("expected a duplicate in the parent directory, got %v", errors)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	workspace.indexFile(first, service)
+	workspace.indexFile(second, "---\n"+service)
+	if errors := workspace.duplicateResources(first); len(errors) != 0 {
+		t.Fatalf("expected no duplicates in a kustomization, got %v", errors)
+	}
+}
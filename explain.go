@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Return the basename of the schema for `id`, which is either a basename, a basename without `.json` or a
+// kind, e.g. `Deployment_apps_v1.json`, `Deployment_apps_v1` or `deployment`
+func findSchemaId(id string) (string, error) {
+	ids, err := schemaIds()
+	if err != nil {
+		return "", fmt.Errorf("get schema ids: %s", err)
+	}
+	for _, candidate := range []string{id, id + ".json"} {
+		if slices.Contains(ids, candidate) {
+			return candidate, nil
+		}
+	}
+	var matches []string
+	for _, candidate := range ids {
+		if strings.EqualFold(schemaIdToGvk(candidate).kind, id) {
+			matches = append(matches, candidate)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no schema found for `%s`, see `yamlls schemas`", id)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("`%s` matches several schemas, use one of %s", id, strings.Join(matches, ", "))
+}
+
+// Read the schema for `id` with all references resolved, return its basename as well
+func readResolvedSchema(id string) (map[string]any, string, error) {
+	basename, err := findSchemaId(id)
+	if err != nil {
+		return nil, "", err
+	}
+	b, err := readSchema(basename)
+	if err != nil {
+		return nil, "", fmt.Errorf("read schema %s: %s", basename, err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(b, &schema); err != nil {
+		return nil, "", fmt.Errorf("unmarshal schema %s: %s", basename, err)
+	}
+	for _, prefix := range []string{"definitions", "$defs"} {
+		if definitions, ok := schema[prefix].(map[string]any); ok {
+			resolved := resolveRefs(schema, definitions, "#/"+prefix+"/", nil).(map[string]any)
+			delete(resolved, prefix)
+			return resolved, basename, nil
+		}
+	}
+	return schema, basename, nil
+}
+
+// Return the schema at `path`, e.g. `spec.template.spec.containers`. Arrays are stepped into
+// automatically, `containers.name` is the name of a container. Indexes such as `containers.0` work too.
+func schemaAtPath(schema map[string]any, path string) (map[string]any, error) {
+	current := schema
+	var walked []string
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '.' }) {
+		walked = append(walked, segment)
+		if _, err := strconv.Atoi(segment); err == nil || segment == "[]" {
+			items, ok := current["items"].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("`%s` is not an array", strings.Join(walked[:len(walked)-1], "."))
+			}
+			current = items
+			continue
+		}
+		next, found := schemaProperties(current)[segment]
+		if !found {
+			return nil, fmt.Errorf("field `%s` does not exist", strings.Join(walked, "."))
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// Return the properties of an object, or of the items in an array, including the ones in allOf, anyOf
+// and oneOf
+func schemaProperties(schema map[string]any) map[string]map[string]any {
+	properties := map[string]map[string]any{}
+	if items, ok := schema["items"].(map[string]any); ok && schema["properties"] == nil {
+		schema = items
+	}
+	if p, ok := schema["properties"].(map[string]any); ok {
+		for name, subSchema := range p {
+			if s, ok := subSchema.(map[string]any); ok {
+				properties[name] = s
+			}
+		}
+	}
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		subSchemas, _ := schema[keyword].([]any)
+		for _, subSchema := range subSchemas {
+			if s, ok := subSchema.(map[string]any); ok {
+				for name, p := range schemaProperties(s) {
+					if _, found := properties[name]; !found {
+						properties[name] = p
+					}
+				}
+			}
+		}
+	}
+	return properties
+}
+
+// Return the fields that are required in an object, or in the items of an array
+func schemaRequired(schema map[string]any) []string {
+	if items, ok := schema["items"].(map[string]any); ok && schema["properties"] == nil {
+		schema = items
+	}
+	var required []string
+	r, _ := schema["required"].([]any)
+	for _, name := range r {
+		if name, ok := name.(string); ok {
+			required = append(required, name)
+		}
+	}
+	return required
+}
+
+// Return a type like in `kubectl explain`, e.g. `string`, `[]Object` or `map[string]string`
+func schemaTypeName(schema map[string]any) string {
+	if schema["x-kubernetes-int-or-string"] == true {
+		return "IntOrString"
+	}
+	var types []string
+	switch t := schema["type"].(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, t := range t {
+			if t, ok := t.(string); ok && t != "null" {
+				types = append(types, t)
+			}
+		}
+	}
+	if len(types) == 0 {
+		for _, keyword := range []string{"oneOf", "anyOf"} {
+			subSchemas, _ := schema[keyword].([]any)
+			for _, subSchema := range subSchemas {
+				if s, ok := subSchema.(map[string]any); ok {
+					if name := schemaTypeName(s); name != "" && !slices.Contains(types, name) {
+						types = append(types, name)
+					}
+				}
+			}
+		}
+		if len(types) > 0 {
+			return strings.Join(types, "|")
+		}
+		if _, ok := schema["properties"]; ok {
+			return "Object"
+		}
+		return ""
+	}
+	switch types[0] {
+	case "array":
+		items, _ := schema["items"].(map[string]any)
+		return "[]" + schemaTypeName(items)
+	case "object":
+		if _, ok := schema["properties"]; ok {
+			return "Object"
+		}
+		if additional, ok := schema["additionalProperties"].(map[string]any); ok {
+			return "map[string]" + schemaTypeName(additional)
+		}
+		return "Object"
+	}
+	return strings.Join(types, "|")
+}
+
+// Write the documentation for the field at `path` like `kubectl explain`. With `recursive`, the nested
+// fields are written as a tree without descriptions.
+func explain(w io.Writer, id, path string, recursive bool) error {
+	schema, basename, err := readResolvedSchema(id)
+	if err != nil {
+		return err
+	}
+	field, err := schemaAtPath(schema, path)
+	if err != nil {
+		return err
+	}
+	gvk := schemaIdToGvk(basename)
+	fmt.Fprintf(w, "KIND:     %s\nVERSION:  %s\n\n", gvk.kind, gvkApiVersion(gvk))
+	if segments := strings.FieldsFunc(path, func(r rune) bool { return r == '.' }); len(segments) > 0 {
+		fmt.Fprintf(w, "FIELD: %s <%s>\n\n", segments[len(segments)-1], schemaTypeName(field))
+	}
+	if description, _ := field["description"].(string); description != "" {
+		fmt.Fprintf(w, "DESCRIPTION:\n%s\n", wrapText(description, 5, 80))
+	}
+	if enum := enumValues(field); len(enum) > 0 {
+		fmt.Fprintf(w, "ENUM:\n%s\n", wrapText(strings.Join(enum, ", "), 5, 80))
+	}
+	properties := schemaProperties(field)
+	if len(properties) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "FIELDS:\n")
+	if recursive {
+		writeFieldTree(w, field, 1)
+		return nil
+	}
+	required := schemaRequired(field)
+	for _, name := range slices.Sorted(maps.Keys(properties)) {
+		p := properties[name]
+		fmt.Fprintf(w, "   %s\t<%s>", name, schemaTypeName(p))
+		if slices.Contains(required, name) {
+			fmt.Fprint(w, " -required-")
+		}
+		fmt.Fprintln(w)
+		description, _ := p["description"].(string)
+		fmt.Fprintf(w, "%s\n", wrapText(description, 5, 80))
+	}
+	return nil
+}
+
+// Recursive definitions are already cut off when resolving references, the depth is limited just in case
+func writeFieldTree(w io.Writer, schema map[string]any, depth int) {
+	properties := schemaProperties(schema)
+	required := schemaRequired(schema)
+	for _, name := range slices.Sorted(maps.Keys(properties)) {
+		p := properties[name]
+		marker := ""
+		if slices.Contains(required, name) {
+			marker = " -required-"
+		}
+		fmt.Fprintf(w, "%s%s\t<%s>%s\n", strings.Repeat("   ", depth), name, schemaTypeName(p), marker)
+		if depth < 32 {
+			writeFieldTree(w, p, depth+1)
+		}
+	}
+}
+
+// Wrap `text` at `width` columns, with each line indented by `indent` spaces
+func wrapText(text string, indent, width int) string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := strings.Repeat(" ", indent)
+		empty := true
+		for _, word := range strings.Fields(paragraph) {
+			if !empty && len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line, empty = strings.Repeat(" ", indent), true
+			}
+			if !empty {
+				line += " "
+			}
+			line += word
+			empty = false
+		}
+		lines = append(lines, strings.TrimRightFunc(line, unicode.IsSpace))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Print the schema at `path` as indented json
+func showSchema(w io.Writer, id, path string) error {
+	schema, _, err := readResolvedSchema(id)
+	if err != nil {
+		return err
+	}
+	field, err := schemaAtPath(schema, path)
+	if err != nil {
+		return err
+	}
+	return writeJson(w, field)
+}
+
+// Score how well `query` matches `s`. All characters in the query must appear in order, case-insensitively.
+// Consecutive characters and characters at the start of a word, after `_`, `.` or `-`, score higher.
+func fuzzyScore(query, s string) (int, bool) {
+	query, s = strings.ToLower(query), strings.ToLower(s)
+	score, i := 0, 0
+	previous := -2
+	for _, q := range query {
+		if q == ' ' {
+			continue
+		}
+		j := strings.IndexRune(s[i:], q)
+		if j < 0 {
+			return 0, false
+		}
+		j += i
+		score++
+		if j == previous+1 {
+			score += 2
+		}
+		if j == 0 || strings.ContainsRune("_.-/", rune(s[j-1])) {
+			score += 3
+		}
+		previous, i = j, j+1
+	}
+	return score, true
+}
+
+type SchemaFilter struct {
+	query, kind, group, version string
+}
+
+// Return the ids that match the filter, the best fuzzy matches first
+func filterSchemaIds(ids []string, filter SchemaFilter) []string {
+	scores := map[string]int{}
+	var matches []string
+	for _, id := range ids {
+		gvk := schemaIdToGvk(id)
+		if filter.kind != "" && !strings.EqualFold(gvk.kind, filter.kind) ||
+			filter.group != "" && !strings.EqualFold(gvk.group, filter.group) ||
+			filter.version != "" && !strings.EqualFold(gvk.version, filter.version) {
+			continue
+		}
+		score, ok := fuzzyScore(filter.query, strings.TrimSuffix(id, ".json"))
+		if !ok {
+			continue
+		}
+		scores[id] = score
+		matches = append(matches, id)
+	}
+	if filter.query == "" {
+		return matches
+	}
+	slices.SortStableFunc(matches, func(a, b string) int {
+		if scores[a] == scores[b] {
+			// Prefer Pod over PodTemplate when searching for pod
+			return len(a) - len(b)
+		}
+		return scores[b] - scores[a]
+	})
+	return matches
+}
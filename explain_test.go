@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Write the schemas to the db of a temporary kubernetes version
+func useTestSchemas(t *testing.T, schemas map[string][]byte) {
+	dbDir, k8sVersion := DB_DIR, K8S_VERSION
+	DB_DIR = t.TempDir()
+	setKubernetesVersion("1.30.0")
+	t.Cleanup(func() {
+		DB_DIR = dbDir
+		setKubernetesVersion(k8sVersion)
+	})
+	if err := os.MkdirAll(schemaDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for basename, schema := range schemas {
+		if err := os.WriteFile(filepath.Join(schemaDir(), basename), schema, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	refs, err := os.ReadFile("testdata/refs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTestSchemas(t, map[string][]byte{"Service_v1.json": serviceV1, "Person_example.com_v1.json": refs})
+
+	tests := map[string]struct {
+		id, path  string
+		recursive bool
+		contains  []string
+	}{
+		"field": {
+			id:   "Service_v1",
+			path: "spec.ports",
+			contains: []string{
+				"KIND:     Service\nVERSION:  v1\n\nFIELD: ports <[]Object>\n\nDESCRIPTION:\n     The list of ports that are exposed by this service.",
+				"   port\t<integer> -required-\n     The port that will be exposed by this service.\n",
+				"   targetPort\t<string|integer>\n",
+			},
+		},
+		"kind-and-index": {
+			id:       "service",
+			path:     ".spec.ports.0.protocol",
+			contains: []string{"FIELD: protocol <string>\n"},
+		},
+		"recursive": {
+			id:        "Service_v1.json",
+			path:      "spec",
+			recursive: true,
+			contains:  []string{"   ports\t<[]Object>\n      appProtocol\t<string>\n", "      port\t<integer> -required-\n"},
+		},
+		"refs": {
+			id:       "Person_example.com_v1",
+			contains: []string{"KIND:     Person\nVERSION:  example.com/v1\n\nDESCRIPTION:\n     A person\n\nFIELDS:\n   name\t<string>\n     The name of the person\n"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := explain(&b, test.id, test.path, test.recursive); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			for _, c := range test.contains {
+				if !strings.Contains(b.String(), c) {
+					t.Fatalf("expected the output to contain\n%s\ngot\n%s", c, b.String())
+				}
+			}
+		})
+	}
+
+	if err := explain(&bytes.Buffer{}, "Service_v1", "spec.port", false); err == nil || err.Error() != "field `spec.port` does not exist" {
+		t.Fatalf("expected an error for a field that doesn't exist, got %v", err)
+	}
+	var b bytes.Buffer
+	if err := showSchema(&b, "Person_example.com_v1", "name"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if b.String() != "{\n  \"description\": \"The name of the person\",\n  \"type\": \"string\"\n}\n" {
+		t.Fatalf("unexpected schema %s", b.String())
+	}
+}
+
+func TestFilterSchemaIds(t *testing.T) {
+	ids := []string{"Deployment_apps_v1.json", "DaemonSet_apps_v1.json", "Deployment_extensions_v1beta1.json", "PodDisruptionBudget_policy_v1.json", "Pod_v1.json"}
+	tests := map[string]struct {
+		filter   SchemaFilter
+		expected []string
+	}{
+		"fuzzy": {
+			filter:   SchemaFilter{query: "deployapps"},
+			expected: []string{"Deployment_apps_v1.json"},
+		},
+		"best-match-first": {
+			filter:   SchemaFilter{query: "pod"},
+			expected: []string{"Pod_v1.json", "PodDisruptionBudget_policy_v1.json"},
+		},
+		"group": {
+			filter:   SchemaFilter{group: "apps"},
+			expected: []string{"Deployment_apps_v1.json", "DaemonSet_apps_v1.json"},
+		},
+		"kind-and-version": {
+			filter:   SchemaFilter{kind: "deployment", version: "v1beta1"},
+			expected: []string{"Deployment_extensions_v1beta1.json"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if actual := filterSchemaIds(ids, test.filter); !slices.Equal(actual, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Kubernetes extends OpenAPI with `x-kubernetes-*` keywords that gojsonschema doesn't know about. Some of
+// them change what is valid, `x-kubernetes-preserve-unknown-fields` and `x-kubernetes-int-or-string`, and
+// are handled by rewriting the schema before validating. The others add constraints, unique entries in
+// `x-kubernetes-list-type` lists and the CEL rules in `x-kubernetes-validations`, and are checked by
+// walking the document alongside the schema.
+
+// The prepared schemas by schema id. Preparing a large schema takes longer than validating a document with
+// it, so it is done once. The original schema is stored to notice when it changes, e.g. when a
+// CustomResourceDefinition in the workspace is edited.
+var (
+	preparedSchemasMu sync.Mutex
+	preparedSchemas   = map[string]preparedSchema{}
+)
+
+type preparedSchema struct {
+	original, prepared []byte
+	parsed             any
+}
+
+// Rewrite the schema so that gojsonschema honours the extensions. The parsed schema is returned as well, it
+// is nil if the schema doesn't use any extensions.
+func prepareSchema(schemaId string, schema []byte) ([]byte, any, error) {
+	if !bytes.Contains(schema, []byte("x-kubernetes-")) {
+		return schema, nil, nil
+	}
+	preparedSchemasMu.Lock()
+	cached, found := preparedSchemas[schemaId]
+	preparedSchemasMu.Unlock()
+	if found && bytes.Equal(cached.original, schema) {
+		return cached.prepared, cached.parsed, nil
+	}
+	var parsed any
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal schema: %s", err)
+	}
+	prepared, err := json.Marshal(prepareSchemaNode(parsed))
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal schema: %s", err)
+	}
+	preparedSchemasMu.Lock()
+	preparedSchemas[schemaId] = preparedSchema{original: schema, prepared: prepared, parsed: parsed}
+	preparedSchemasMu.Unlock()
+	return prepared, parsed, nil
+}
+
+func prepareSchemaNode(node any) any {
+	switch n := node.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(n))
+		for key, value := range n {
+			switch key {
+			case "enum", "const", "default", "example", "required", "x-kubernetes-validations":
+				result[key] = value
+			default:
+				result[key] = prepareSchemaNode(value)
+			}
+		}
+		if n["x-kubernetes-preserve-unknown-fields"] == true && n["additionalProperties"] == false {
+			delete(result, "additionalProperties")
+		}
+		_, hasAnyOf := n["anyOf"]
+		_, hasOneOf := n["oneOf"]
+		if n["x-kubernetes-int-or-string"] == true && !hasAnyOf && !hasOneOf {
+			delete(result, "type")
+			delete(result, "format")
+			result["anyOf"] = []any{map[string]any{"type": "string"}, map[string]any{"type": "integer"}}
+		}
+		return result
+	case []any:
+		result := make([]any, len(n))
+		for i, e := range n {
+			result[i] = prepareSchemaNode(e)
+		}
+		return result
+	default:
+		return node
+	}
+}
+
+type ExtensionError struct {
+	Path    string
+	Message string
+	Type    string
+	Severity
+}
+
+// Check the unique entries in `x-kubernetes-list-type` lists and the `x-kubernetes-validations` rules
+func extensionErrors(schema, value any, path string) []ExtensionError {
+	s, ok := schema.(map[string]any)
+	if !ok || value == nil {
+		return nil
+	}
+	var errors []ExtensionError
+	if rules, ok := s["x-kubernetes-validations"].([]any); ok {
+		for _, r := range rules {
+			rule, _ := r.(map[string]any)
+			expression, _ := rule["rule"].(string)
+			compiled, err := celCompile(expression)
+			if err != nil {
+				// E.g. transition rules that use oldSelf
+				continue
+			}
+			if celRuleHolds(compiled, celValue(s, value)) {
+				continue
+			}
+			message, _ := rule["message"].(string)
+			if message == "" {
+				message = fmt.Sprintf("failed rule: %s", expression)
+			}
+			errors = append(errors, ExtensionError{Path: path, Message: message, Type: "validation_rule"})
+		}
+	}
+	if allOf, ok := s["allOf"].([]any); ok {
+		for _, subSchema := range allOf {
+			errors = append(errors, extensionErrors(subSchema, value, path)...)
+		}
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := s["properties"].(map[string]any)
+		for key, child := range v {
+			if subSchema, found := properties[key]; found {
+				errors = append(errors, extensionErrors(subSchema, child, joinPath(path, key))...)
+			} else if subSchema, ok := s["additionalProperties"].(map[string]any); ok {
+				errors = append(errors, extensionErrors(subSchema, child, joinPath(path, key))...)
+			}
+		}
+	case []any:
+		errors = append(errors, duplicateListEntries(s, v, path)...)
+		for i, child := range v {
+			errors = append(errors, extensionErrors(s["items"], child, joinPath(path, fmt.Sprint(i)))...)
+		}
+	}
+	return errors
+}
+
+// Return the entries in a `set` or `map` list that are equal to an earlier entry. Lists that are merged by
+// a key when patching, like containers by name, are treated as maps as well, but the API server only warns
+// about those.
+func duplicateListEntries(schema map[string]any, list []any, path string) []ExtensionError {
+	listType, _ := schema["x-kubernetes-list-type"].(string)
+	var keys []string
+	severity := SEVERITY_ERROR
+	switch listType {
+	case "set":
+	case "map":
+		mapKeys, _ := schema["x-kubernetes-list-map-keys"].([]any)
+		for _, k := range mapKeys {
+			if k, ok := k.(string); ok {
+				keys = append(keys, k)
+			}
+		}
+	case "":
+		if key, ok := schema["x-kubernetes-patch-merge-key"].(string); ok && schema["x-kubernetes-patch-strategy"] != nil {
+			keys = []string{key}
+			severity = SEVERITY_WARN
+		}
+	}
+	if listType != "set" && len(keys) == 0 {
+		return nil
+	}
+
+	var errors []ExtensionError
+	var seen []any
+	for i, entry := range list {
+		var identity any = entry
+		var described []string
+		if listType != "set" {
+			object, ok := entry.(map[string]any)
+			if !ok {
+				continue
+			}
+			values := make([]any, len(keys))
+			for j, key := range keys {
+				values[j] = object[key]
+				if object[key] != nil {
+					described = append(described, fmt.Sprintf("%s `%v`", key, object[key]))
+				}
+			}
+			if len(described) == 0 {
+				continue
+			}
+			identity = values
+		}
+		duplicate := false
+		for _, s := range seen {
+			if reflect.DeepEqual(s, identity) {
+				duplicate = true
+				break
+			}
+		}
+		seen = append(seen, identity)
+		if !duplicate {
+			continue
+		}
+		entryPath := joinPath(path, fmt.Sprint(i))
+		var message string
+		if listType == "set" {
+			message = fmt.Sprintf("duplicate entry `%v`", entry)
+		} else {
+			message = fmt.Sprintf("duplicate entry with %s", strings.Join(described, " and "))
+			entryPath = joinPath(entryPath, keys[0])
+		}
+		errors = append(errors, ExtensionError{Path: entryPath, Message: message, Type: "duplicate_list_entry", Severity: severity})
+	}
+	return errors
+}
+
+func joinPath(path, segment string) string {
+	if path == "." {
+		return "." + segment
+	}
+	return path + "." + segment
+}
+
+// Return the range of `path`, or of the closest parent that is in `paths`
+func closestPathRange(paths Paths, path string) Range {
+	for {
+		if range_, found := paths[path]; found {
+			return range_
+		}
+		i := strings.LastIndex(path, ".")
+		if i <= 0 {
+			return paths["."]
+		}
+		path = path[:i]
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"testing"
+)
+
+//go:embed testdata/x-kubernetes-extensions.json
+var extensionsSchema []byte
+
+func TestKubernetesExtensions(t *testing.T) {
+	schemaCache["Thing_example.com_v1.json"] = extensionsSchema
+	t.Cleanup(func() { delete(schemaCache, "Thing_example.com_v1.json") })
+
+	tests := map[string]struct {
+		spec   string
+		errors []ValidationError
+	}{
+		"valid": {
+			spec: `spec:
+  minReplicas: 1
+  maxReplicas: 2
+  suffix: -abc
+  port: 80
+  config:
+    anything: goes
+  finalizers: [a, b]
+  ports:
+    - port: 80
+      protocol: TCP
+    - port: 80
+      protocol: UDP
+  containers:
+    - name: app
+    - name: sidecar
+`,
+		},
+		"int-or-string": {
+			spec: `spec:
+  port: http
+`,
+		},
+		"validation-rule-message": {
+			spec: `spec:
+  minReplicas: 3
+  maxReplicas: 2
+`,
+			errors: []ValidationError{
+				{Range: newRange(2, 0, 2, 4), Type: "validation_rule", Message: "minReplicas must not be greater than maxReplicas", SchemaId: "Thing_example.com_v1", Path: ".spec"},
+			},
+		},
+		"validation-rule-without-message": {
+			spec: `spec:
+  suffix: abc
+`,
+			errors: []ValidationError{
+				{Range: newRange(2, 0, 2, 4), Type: "validation_rule", Message: "failed rule: !has(self.suffix) || self.suffix.startsWith('-')", SchemaId: "Thing_example.com_v1", Path: ".spec"},
+			},
+		},
+		"validation-rule-with-double": {
+			spec: `spec:
+  ratio: 3
+`,
+		},
+		"duplicate-set-entry": {
+			spec: `spec:
+  finalizers:
+    - a
+    - a
+`,
+			errors: []ValidationError{
+				{Range: newRange(5, 6, 5, 7), Type: "duplicate_list_entry", Message: "duplicate entry `a`", SchemaId: "Thing_example.com_v1", Path: ".spec.finalizers.1"},
+			},
+		},
+		"duplicate-map-entry": {
+			spec: `spec:
+  ports:
+    - port: 80
+      protocol: TCP
+    - port: 80
+      protocol: TCP
+`,
+			errors: []ValidationError{
+				{Range: newRange(6, 6, 6, 10), Type: "duplicate_list_entry", Message: "duplicate entry with port `80` and protocol `TCP`", SchemaId: "Thing_example.com_v1", Path: ".spec.ports.1.port"},
+			},
+		},
+		"duplicate-container-name": {
+			spec: `spec:
+  containers:
+    - name: app
+      image: nginx
+    - name: app
+      image: busybox
+`,
+			errors: []ValidationError{
+				{Range: newRange(6, 6, 6, 10), Type: "duplicate_list_entry", Message: "duplicate entry with name `app`", Severity: SEVERITY_WARN, SchemaId: "Thing_example.com_v1", Path: ".spec.containers.1.name"},
+			},
+		},
+		"additional-property": {
+			spec: `spec:
+  replicas: 1
+`,
+			errors: []ValidationError{
+				{Range: newRange(3, 2, 3, 10), Type: "additional_property_not_allowed", Message: "Additional property replicas is not allowed", SchemaId: "Thing_example.com_v1", Path: ".spec.replicas"},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			contents := "apiVersion: example.com/v1\nkind: Thing\n" + test.spec
+			errors, fail := fileValidate(contents)
+			if fail != VALIDATION_FAILURE_REASON_NOT_A_FAILURE {
+				t.Fatalf("expected validation to work, got %s", fail)
+			}
+			if len(errors) != len(test.errors) {
+				t.Fatalf("expected %d errors, got %v", len(test.errors), errors)
+			}
+			for i := range errors {
+				if errors[i] != test.errors[i] {
+					t.Fatalf("expected %v, got %v", test.errors[i], errors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPrepareSchemaCache(t *testing.T) {
+	t.Cleanup(func() { delete(preparedSchemas, "Cached_example.com_v1") })
+	schema := []byte(`{"type": "object", "x-kubernetes-preserve-unknown-fields": true, "additionalProperties": false}`)
+	first, _, err := prepareSchema("Cached_example.com_v1", schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := prepareSchema("Cached_example.com_v1", bytes.Clone(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &first[0] != &second[0] {
+		t.Fatalf("expected the prepared schema to be reused")
+	}
+	changed, _, err := prepareSchema("Cached_example.com_v1", []byte(`{"type": "string", "x-kubernetes-preserve-unknown-fields": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(changed) == string(first) {
+		t.Fatalf("expected a changed schema to be prepared again")
+	}
+}
@@ -10,14 +10,16 @@ type DocumentPosition struct {
 	start, end int
 }
 
+// Split a file into its documents. The start and end are lines in the file, blank lines included.
+// Documents with only blank lines are skipped, like empty ones.
 func documentsInFile(file string) []DocumentPosition {
 	documents := []DocumentPosition{}
 	docBuilder := strings.Builder{}
 	currentStart := 0
-	lines := strings.FieldsFunc(file, func(r rune) bool { return r == '\n' })
+	lines := strings.Split(strings.TrimSuffix(file, "\n"), "\n")
 	for i, line := range lines {
-		if line == "---" {
-			if docBuilder.Len() > 0 {
+		if isDocumentSeparator(line) {
+			if strings.TrimSpace(docBuilder.String()) != "" {
 				documents = append(documents, DocumentPosition{
 					document: docBuilder.String(),
 					start:    currentStart,
@@ -30,7 +32,7 @@ func documentsInFile(file string) []DocumentPosition {
 			fmt.Fprintf(&docBuilder, "%s\n", line)
 		}
 	}
-	if docBuilder.Len() > 0 {
+	if strings.TrimSpace(docBuilder.String()) != "" {
 		documents = append(documents, DocumentPosition{
 			document: docBuilder.String(),
 			start:    currentStart,
@@ -40,6 +42,10 @@ func documentsInFile(file string) []DocumentPosition {
 	return documents
 }
 
+func isDocumentSeparator(line string) bool {
+	return strings.TrimRight(line, " \t") == "---"
+}
+
 func documentAtPosition(file string, line int) (string, int, bool) {
 	documents := documentsInFile(file)
 	for _, doc := range documents {
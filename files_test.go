@@ -96,6 +96,62 @@ hej: du
 				},
 			},
 		},
+		"blank-lines": {
+			file: `hej: du
+
+---
+
+hej: hej
+`,
+			documents: []DocumentPosition{
+				{
+					document: `hej: du
+
+`,
+					start: 0,
+					end:   2,
+				},
+				{
+					document: `
+hej: hej
+`,
+					start: 3,
+					end:   5,
+				},
+			},
+		},
+		"separator-with-trailing-space": {
+			file: "hej: du\n--- \nhej: hej\n",
+			documents: []DocumentPosition{
+				{
+					document: `hej: du
+`,
+					start: 0,
+					end:   1,
+				},
+				{
+					document: `hej: hej
+`,
+					start: 2,
+					end:   3,
+				},
+			},
+		},
+		"blank-document": {
+			file: `hej: du
+---
+  
+---
+`,
+			documents: []DocumentPosition{
+				{
+					document: `hej: du
+`,
+					start: 0,
+					end:   1,
+				},
+			},
+		},
 	}
 
 	for name, test := range tests {
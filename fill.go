@@ -0,0 +1,381 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/token"
+	"github.com/tidwall/gjson"
+)
+
+// Recursive schemas are cut off at this depth when filling required properties
+const MAX_FILL_DEPTH = 32
+
+// These keys come first when filling, the other keys are in the same order as in the schema
+var fillKeyOrder = []string{"kind", "apiVersion", "metadata", "spec"}
+
+type FillOptions struct {
+	// Only fill the required properties, recursively
+	required bool
+	// The levels of properties to fill, nested objects below it are `{}`. Defaults to 1, or
+	// MAX_FILL_DEPTH when only filling required properties.
+	depth int
+	// Use the default, the first example or the first enum value instead of a zero value
+	defaults bool
+}
+
+// The options for the fill code action, set from the `fillDepth` and `fillDefaults` options of the
+// language server
+var FILL_OPTIONS = FillOptions{}
+
+// Return a yaml document with values for the properties of the schema at `path`
+func schemaFill(rootSchemaBytes []byte, path string, options FillOptions) (string, error) {
+	result, err := fillSchema(rootSchemaBytes, path, options)
+	if err != nil {
+		return "", err
+	}
+	yamlBytes, err := yaml.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("marshal filled schema to yaml: %s", err)
+	}
+	return string(yamlBytes), nil
+}
+
+// Return the values for the schema at `path`, objects are yaml.MapSlice to keep the order of the keys
+func fillSchema(rootSchemaBytes []byte, path string, options FillOptions) (any, error) {
+	schema := gjson.ParseBytes(rootSchemaBytes)
+	if path != "." {
+		schemaPath := pathToSchemaPath(path)
+		schema = gjson.GetBytes(rootSchemaBytes, schemaPath)
+		if !schema.Exists() {
+			return nil, fmt.Errorf("no schema found at path `%s`", schemaPath)
+		}
+	}
+	if options.depth <= 0 {
+		options.depth = 1
+		if options.required {
+			options.depth = MAX_FILL_DEPTH
+		}
+	}
+	if path == "." && options.required {
+		// The api server requires kind and apiVersion even though the schemas don't
+		return fillProperties(schema, options, options.depth, []string{"kind", "apiVersion"}), nil
+	}
+	return fillValue(schema, options, options.depth), nil
+}
+
+func fillValue(schema gjson.Result, options FillOptions, depth int) any {
+	if depth > 0 && schema.Get("properties").IsObject() {
+		return fillProperties(schema, options, depth, nil)
+	}
+	if options.defaults {
+		for _, example := range []gjson.Result{schema.Get("default"), schema.Get("examples.0"), schema.Get("example")} {
+			if example.Exists() {
+				return jsonValue(example)
+			}
+		}
+	}
+	switch {
+	case schema.Get("properties").IsObject():
+		return yaml.MapSlice{}
+	case schema.Get("items").IsObject():
+		items := schema.Get("items")
+		if depth == 0 || options.required && !items.Get("required").IsArray() {
+			return []any{}
+		}
+		return []any{fillValue(items, options, depth)}
+	case schema.Get("enum").IsArray():
+		return jsonValue(schema.Get("enum.0"))
+	case schema.Get("const").Exists():
+		return jsonValue(schema.Get("const"))
+	case schema.Get("type").Type == gjson.String:
+		return typeZeroValue(schema.Get("type").String())
+	case schema.Get("type").IsArray():
+		for _, t := range schema.Get("type").Array() {
+			if t.String() != "null" {
+				return typeZeroValue(t.String())
+			}
+		}
+		return nil
+	case schema.Get("anyOf").IsArray():
+		return fillValue(schema.Get("anyOf.0"), options, depth)
+	case schema.Get("oneOf").IsArray():
+		return fillValue(schema.Get("oneOf.0"), options, depth)
+	case schema.Get("x-kubernetes-int-or-string").Bool():
+		return ""
+	}
+	return nil
+}
+
+// Fill the properties of an object in schema order, with the keys in fillKeyOrder first. When only
+// filling required properties, `alsoRequired` are filled as well.
+func fillProperties(schema gjson.Result, options FillOptions, depth int, alsoRequired []string) yaml.MapSlice {
+	var required []string
+	for _, name := range schema.Get("required").Array() {
+		required = append(required, name.String())
+	}
+	required = append(required, alsoRequired...)
+	var names []string
+	schema.Get("properties").ForEach(func(key, _ gjson.Result) bool {
+		if !options.required || slices.Contains(required, key.String()) {
+			names = append(names, key.String())
+		}
+		return true
+	})
+	slices.SortStableFunc(names, func(a, b string) int {
+		return fillKeyRank(a) - fillKeyRank(b)
+	})
+	result := yaml.MapSlice{}
+	for _, name := range names {
+		property := schema.Get("properties").Get(gjson.Escape(name))
+		result = append(result, yaml.MapItem{Key: name, Value: fillValue(property, options, depth-1)})
+	}
+	return result
+}
+
+func fillKeyRank(key string) int {
+	if i := slices.Index(fillKeyOrder, key); i >= 0 {
+		return i
+	}
+	return len(fillKeyOrder)
+}
+
+// Return the value of a json value, with whole numbers as integers so that they aren't written as `200.0`
+func jsonValue(value gjson.Result) any {
+	if value.Type == gjson.Number && value.Num == math.Trunc(value.Num) {
+		return int(value.Num)
+	}
+	return value.Value()
+}
+
+func typeZeroValue(t string) any {
+	switch t {
+	case "boolean":
+		return false
+	case "integer", "number":
+		return 0
+	case "object":
+		return yaml.MapSlice{}
+	case "string":
+		return ""
+	case "array":
+		return []any{}
+	}
+	return nil
+}
+
+// An edit in a document, an insertion if the range is empty
+type TextEdit struct {
+	Range   Range
+	NewText string
+}
+
+type YamlStyle struct {
+	// The number of spaces to indent nested objects with
+	indent int
+	// Whether sequences are indented under their key, `ports:\n  - port: 80`, or not, `ports:\n- port: 80`
+	indentSequences bool
+}
+
+// Detect the indentation from the first nested objects and sequences in the document. Defaults to two
+// spaces and sequences that aren't indented, like kubectl.
+func detectYamlStyle(lines []string) YamlStyle {
+	style := YamlStyle{indent: 2}
+	var indentFound, sequenceFound bool
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") || !strings.HasSuffix(trimmed, ":") {
+			continue
+		}
+		for _, next := range lines[i+1:] {
+			nextTrimmed := strings.TrimSpace(next)
+			if nextTrimmed == "" || strings.HasPrefix(nextTrimmed, "#") {
+				continue
+			}
+			keyIndent, nextIndent := yamlIndent(line), len(next)-len(strings.TrimLeft(next, " "))
+			isSequence := strings.HasPrefix(nextTrimmed, "- ") || nextTrimmed == "-"
+			if isSequence && !sequenceFound {
+				style.indentSequences, sequenceFound = nextIndent > keyIndent, true
+			}
+			if !isSequence && !indentFound && nextIndent > keyIndent {
+				style.indent, indentFound = nextIndent-keyIndent, true
+			}
+			break
+		}
+		if indentFound && sequenceFound {
+			break
+		}
+	}
+	return style
+}
+
+// Return the column of the key on a line, `- - name:` is indented by 4
+func yamlIndent(line string) int {
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	for rest := line[indent:]; strings.HasPrefix(rest, "- "); rest = line[indent:] {
+		indent += 2
+		indent += len(line[indent:]) - len(strings.TrimLeft(line[indent:], " "))
+	}
+	return indent
+}
+
+type fillMerger struct {
+	lines []string
+	style YamlStyle
+	edits []TextEdit
+}
+
+// Return the edits that add the values in `filled` that are missing at `path` in `doc`. Existing values
+// and comments are kept, scalars are added on the same line as their key.
+func fillEdits(doc, path string, filled any) ([]TextEdit, error) {
+	body, err := parseYamlDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	key, _, node, found := yamlNodeAtPath(body, path)
+	if !found {
+		return nil, fmt.Errorf("no field at `%s`", path)
+	}
+	lines := strings.Split(doc, "\n")
+	m := fillMerger{lines: lines, style: detectYamlStyle(lines)}
+	m.merge(key, node, filled)
+	return m.edits, nil
+}
+
+// Merge `filled` into `value`, which is the value of `key` if it isn't an item in a sequence
+func (m *fillMerger) merge(key *ast.MappingValueNode, value ast.Node, filled any) {
+	switch v := value.(type) {
+	case *ast.MappingNode:
+		if !v.IsFlowStyle {
+			if filled, ok := filled.(yaml.MapSlice); ok {
+				m.mergeMapping(v.Values, filled)
+			}
+		} else if len(v.Values) == 0 && key != nil {
+			m.replaceEmpty(key, v.End, filled)
+		}
+	case *ast.MappingValueNode:
+		if filled, ok := filled.(yaml.MapSlice); ok {
+			m.mergeMapping([]*ast.MappingValueNode{v}, filled)
+		}
+	case *ast.SequenceNode:
+		if !v.IsFlowStyle {
+			if filled, ok := filled.([]any); ok && len(filled) > 0 {
+				for _, item := range v.Values {
+					m.merge(nil, unwrapYamlNode(item), filled[0])
+				}
+			}
+		} else if len(v.Values) == 0 && key != nil {
+			m.replaceEmpty(key, v.End, filled)
+		}
+	case nil, *ast.NullNode:
+		if key != nil && m.isBlankAfter(key.Start.Position) {
+			m.addValue(key, filled)
+		}
+	}
+}
+
+func (m *fillMerger) mergeMapping(values []*ast.MappingValueNode, filled yaml.MapSlice) {
+	var missing yaml.MapSlice
+	for _, item := range filled {
+		key, _ := item.Key.(string)
+		if existing := findMappingValue(values, key); existing != nil {
+			m.merge(existing, unwrapYamlNode(existing.Value), item.Value)
+		} else {
+			missing = append(missing, item)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	start := values[0].Key.GetToken().Position
+	column := start.Column - 1
+	// Add the missing keys after the last line that is indented at least as much as the keys
+	end := start.Line - 1
+	for i := end + 1; i < len(m.lines); i++ {
+		line := m.lines[i]
+		trimmed := strings.TrimSpace(line)
+		if isDocumentSeparator(line) {
+			break
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if len(line)-len(strings.TrimLeft(line, " ")) < column {
+			break
+		}
+		end = i
+	}
+	m.insertLines(end+1, m.render(missing, column))
+}
+
+// Add a value to a key without one, on the same line if it is a scalar or empty
+func (m *fillMerger) addValue(key *ast.MappingValueNode, filled any) {
+	colon := key.Start.Position
+	if isEmptyFill(filled) {
+		b, _ := yaml.Marshal(filled)
+		line := colon.Line - 1
+		m.edits = append(m.edits, TextEdit{
+			Range:   newRange(line, colon.Column, line, colon.Column),
+			NewText: " " + strings.TrimSpace(string(b)),
+		})
+		return
+	}
+	m.insertLines(colon.Line, m.render(filled, m.valueColumn(key, filled)))
+}
+
+// Replace an empty flow value, `{}` or `[]`, with the filled values on the lines below
+func (m *fillMerger) replaceEmpty(key *ast.MappingValueNode, end *token.Token, filled any) {
+	if isEmptyFill(filled) {
+		return
+	}
+	colon := key.Start.Position
+	m.edits = append(m.edits, TextEdit{
+		Range: newRange(colon.Line-1, colon.Column, end.Position.Line-1, end.Position.Column),
+	})
+	m.insertLines(end.Position.Line, m.render(filled, m.valueColumn(key, filled)))
+}
+
+// The column of the value of `key` when it is on the lines below it
+func (m *fillMerger) valueColumn(key *ast.MappingValueNode, filled any) int {
+	column := key.Key.GetToken().Position.Column - 1
+	if _, isSequence := filled.([]any); isSequence && !m.style.indentSequences {
+		return column
+	}
+	return column + m.style.indent
+}
+
+func (m *fillMerger) isBlankAfter(colon *token.Position) bool {
+	rest := strings.TrimSpace(m.lines[colon.Line-1][colon.Column:])
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+func (m *fillMerger) insertLines(line int, text string) {
+	m.edits = append(m.edits, TextEdit{Range: newRange(line, 0, line, 0), NewText: text})
+}
+
+// Render `value` as yaml in the style of the document, starting at `column`
+func (m *fillMerger) render(value any, column int) string {
+	b, err := yaml.MarshalWithOptions(value, yaml.Indent(m.style.indent), yaml.IndentSequence(m.style.indentSequences))
+	if err != nil {
+		return ""
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		lines = append(lines, strings.Repeat(" ", column)+line)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func isEmptyFill(filled any) bool {
+	switch f := filled.(type) {
+	case yaml.MapSlice:
+		return len(f) == 0
+	case []any:
+		return len(f) == 0
+	}
+	return true
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const fillTestSchema = `{
+  "type": "object",
+  "properties": {
+    "status": {"type": "object", "properties": {"ready": {"type": "boolean"}}},
+    "spec": {
+      "type": "object",
+      "required": ["replicas", "ports"],
+      "properties": {
+        "replicas": {"type": "integer", "default": 1},
+        "paused": {"type": "boolean"},
+        "strategy": {"type": "string", "enum": ["Recreate", "RollingUpdate"]},
+        "image": {"type": "string", "examples": ["nginx:1.27"]},
+        "ports": {
+          "type": "array",
+          "items": {
+            "type": "object",
+            "required": ["port"],
+            "properties": {
+              "protocol": {"type": "string", "default": "TCP"},
+              "port": {"type": "integer"}
+            }
+          }
+        }
+      }
+    },
+    "metadata": {"type": "object", "properties": {"name": {"type": "string"}}},
+    "apiVersion": {"type": "string", "enum": ["example.com/v1"]},
+    "kind": {"type": "string", "enum": ["Example"]}
+  }
+}`
+
+func TestFillOptions(t *testing.T) {
+	tests := map[string]struct {
+		path     string
+		options  FillOptions
+		expected string
+	}{
+		"one-level": {
+			path:    ".",
+			options: FillOptions{},
+			expected: `kind: Example
+apiVersion: example.com/v1
+metadata: {}
+spec: {}
+status: {}
+`,
+		},
+		"depth": {
+			path:    ".",
+			options: FillOptions{depth: 2},
+			expected: `kind: Example
+apiVersion: example.com/v1
+metadata:
+  name: ""
+spec:
+  replicas: 0
+  paused: false
+  strategy: Recreate
+  image: ""
+  ports: []
+status:
+  ready: false
+`,
+		},
+		"required": {
+			path:    ".",
+			options: FillOptions{required: true},
+			expected: `kind: Example
+apiVersion: example.com/v1
+`,
+		},
+		"required-at-path": {
+			path:    ".spec",
+			options: FillOptions{required: true},
+			expected: `replicas: 0
+ports:
+- port: 0
+`,
+		},
+		"defaults": {
+			path:    ".spec",
+			options: FillOptions{depth: 3, defaults: true},
+			expected: `replicas: 1
+paused: false
+strategy: Recreate
+image: nginx:1.27
+ports:
+- protocol: TCP
+  port: 0
+`,
+		},
+		"array": {
+			path:    ".spec.ports",
+			options: FillOptions{required: true, defaults: true},
+			expected: `- port: 0
+`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			filled, err := schemaFill([]byte(fillTestSchema), test.path, test.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if filled != test.expected {
+				t.Fatalf("expected\n`%s`\ngot\n`%s`", test.expected, filled)
+			}
+		})
+	}
+}
+
+func TestFillEdits(t *testing.T) {
+	tests := map[string]struct {
+		doc, path string
+		options   FillOptions
+		expected  string
+	}{
+		"inline-scalar": {
+			doc:      "spec:\n  replicas:\n",
+			path:     ".spec.replicas",
+			expected: "spec:\n  replicas: 0\n",
+		},
+		"comment-after-key": {
+			doc:      "status: # comment\n",
+			path:     ".status",
+			expected: "status: # comment\n  ready: false\n",
+		},
+		"keep-existing": {
+			doc: `kind: Example
+spec:
+  # The number of pods
+  replicas: 3 # three
+  image: nginx
+metadata:
+  name: web
+`,
+			path: ".spec",
+			expected: `kind: Example
+spec:
+  # The number of pods
+  replicas: 3 # three
+  image: nginx
+  paused: false
+  strategy: Recreate
+  ports: []
+metadata:
+  name: web
+`,
+		},
+		"detect-indentation": {
+			doc: `metadata:
+    name: web
+spec:
+`,
+			path:    ".spec",
+			options: FillOptions{required: true},
+			expected: `metadata:
+    name: web
+spec:
+    replicas: 0
+    ports:
+    - port: 0
+`,
+		},
+		"detect-indented-sequences": {
+			doc: `spec:
+  ports:
+    - port: 80
+  strategy: Recreate
+`,
+			path:    ".",
+			options: FillOptions{depth: 3},
+			expected: `spec:
+  ports:
+    - port: 80
+      protocol: ""
+  strategy: Recreate
+  replicas: 0
+  paused: false
+  image: ""
+kind: Example
+apiVersion: example.com/v1
+metadata:
+  name: ""
+status:
+  ready: false
+`,
+		},
+		"empty-flow": {
+			doc:      "spec: {} # comment\n",
+			path:     ".spec",
+			options:  FillOptions{required: true},
+			expected: "spec: # comment\n  replicas: 0\n  ports:\n  - port: 0\n",
+		},
+		"sequence-items": {
+			doc: `spec:
+  ports:
+  - port: 80
+  - protocol: UDP
+    port: 53
+`,
+			path:    ".spec.ports",
+			options: FillOptions{defaults: true},
+			expected: `spec:
+  ports:
+  - port: 80
+    protocol: TCP
+  - protocol: UDP
+    port: 53
+`,
+		},
+		"nothing-to-fill": {
+			doc:      "spec:\n  replicas: 1\n",
+			path:     ".spec.replicas",
+			expected: "spec:\n  replicas: 1\n",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			filled, err := fillSchema([]byte(fillTestSchema), test.path, test.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			edits, err := fillEdits(test.doc, test.path, filled)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if actual := applyTextEdits(test.doc, edits); actual != test.expected {
+				t.Fatalf("expected\n%s\ngot\n%s", test.expected, actual)
+			}
+		})
+	}
+}
+
+// Apply edits like an LSP client, edits at the same position are inserted in order
+func applyTextEdits(doc string, edits []TextEdit) string {
+	lines := strings.SplitAfter(doc, "\n")
+	offset := func(p Position) int {
+		n := 0
+		for _, line := range lines[:min(p.Line, len(lines))] {
+			n += len(line)
+		}
+		return n + p.Char
+	}
+	slices.SortStableFunc(edits, func(a, b TextEdit) int {
+		return offset(b.Range.Start) - offset(a.Range.Start)
+	})
+	// Edits at the same position are reversed by applying them from the end
+	for i := 0; i < len(edits); {
+		j := i
+		for j < len(edits) && edits[j].Range.Start == edits[i].Range.Start {
+			j++
+		}
+		slices.Reverse(edits[i:j])
+		i = j
+	}
+	for _, e := range edits {
+		doc = doc[:offset(e.Range.Start)] + e.NewText + doc[offset(e.Range.End):]
+	}
+	return doc
+}
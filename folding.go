@@ -0,0 +1,272 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/token"
+	"go.lsp.dev/protocol"
+)
+
+// The protocol package has the types for selection ranges but not the method
+const METHOD_TEXT_DOCUMENT_SELECTION_RANGE = "textDocument/selectionRange"
+
+// Return the folding ranges of `file`: each document, each mapping and sequence under its key or `-`,
+// block scalars and groups of comments. Editors use one range per line, so the first one is kept: the
+// outermost node, then comments and then the document.
+func foldingRanges(file string) []protocol.FoldingRange {
+	lines := strings.Split(file, "\n")
+	ranges := map[uint32]protocol.FoldingRange{}
+	add := func(start, end int, kind protocol.FoldingRangeKind) {
+		if end <= start {
+			return
+		}
+		if _, found := ranges[uint32(start)]; !found {
+			ranges[uint32(start)] = protocol.FoldingRange{StartLine: uint32(start), EndLine: uint32(end), Kind: kind}
+		}
+	}
+	for _, doc := range documentsInFile(file) {
+		first, last := contentLines(lines, doc.start, doc.end)
+		if first == -1 {
+			continue
+		}
+		if body, err := parseYamlDocument(doc.document); err == nil && body != nil {
+			ast.Walk(foldingVisitor(func(start, end protocol.Position) {
+				add(doc.start+int(start.Line), doc.start+int(end.Line), "")
+			}), body)
+		}
+		commentStart := -1
+		for i := first; i <= last+1; i++ {
+			if i <= last && strings.HasPrefix(strings.TrimSpace(lines[i]), "#") {
+				if commentStart == -1 {
+					commentStart = i
+				}
+				continue
+			}
+			if commentStart != -1 {
+				add(commentStart, i-1, protocol.CommentFoldingRange)
+			}
+			commentStart = -1
+		}
+		// From the `---` if there is one, so that the whole document can be folded
+		if doc.start > 0 && isDocumentSeparator(lines[doc.start-1]) {
+			first = doc.start - 1
+		}
+		add(first, last, protocol.RegionFoldingRange)
+	}
+	result := []protocol.FoldingRange{}
+	for _, r := range ranges {
+		result = append(result, r)
+	}
+	slices.SortFunc(result, func(a, b protocol.FoldingRange) int { return int(a.StartLine) - int(b.StartLine) })
+	return result
+}
+
+// Calls the function with the range of each key and value and each entry in a sequence, outermost first
+type foldingVisitor func(start, end protocol.Position)
+
+func (f foldingVisitor) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.MappingValueNode:
+		if end, ok := yamlNodeEnd(n.Value); ok {
+			f(yamlNodeStart(n), end)
+		}
+	case *ast.SequenceNode:
+		for _, item := range n.Values {
+			if end, ok := yamlNodeEnd(item); ok {
+				f(yamlNodeStart(item), end)
+			}
+		}
+	}
+	return f
+}
+
+// Return the selection ranges at `positions` in `file`. Each one goes from the scalar at the position to
+// the key and value, the mappings and sequences it is in and finally the document.
+func selectionRanges(file string, positions []protocol.Position) []protocol.SelectionRange {
+	lines := strings.Split(file, "\n")
+	documents := documentsInFile(file)
+	result := make([]protocol.SelectionRange, 0, len(positions))
+	for _, position := range positions {
+		selection := protocol.SelectionRange{Range: protocol.Range{Start: position, End: position}}
+		for _, doc := range documents {
+			if int(position.Line) < doc.start || doc.end <= int(position.Line) {
+				continue
+			}
+			first, last := contentLines(lines, doc.start, doc.end)
+			if first == -1 {
+				break
+			}
+			chain := []protocol.Range{{
+				Start: protocol.Position{Line: uint32(first)},
+				End:   protocol.Position{Line: uint32(last), Character: uint32(len(strings.TrimRight(lines[last], " \t")))},
+			}}
+			if !rangeContains(chain[0], position) {
+				break
+			}
+			if body, err := parseYamlDocument(doc.document); err == nil && body != nil {
+				offset := uint32(doc.start)
+				inDocument := protocol.Position{Line: position.Line - offset, Character: position.Character}
+				for _, r := range selectionChain(body, inDocument) {
+					r.Start.Line += offset
+					r.End.Line += offset
+					if r != chain[len(chain)-1] {
+						chain = append(chain, r)
+					}
+				}
+			}
+			var parent *protocol.SelectionRange
+			for _, r := range chain {
+				parent = &protocol.SelectionRange{Range: r, Parent: parent}
+			}
+			selection = *parent
+			break
+		}
+		result = append(result, selection)
+	}
+	return result
+}
+
+// Return the ranges of the nodes that contain `position`, outermost first
+func selectionChain(node ast.Node, position protocol.Position) []protocol.Range {
+	end, ok := yamlNodeEnd(node)
+	if !ok {
+		return nil
+	}
+	r := protocol.Range{Start: yamlNodeStart(node), End: end}
+	if !rangeContains(r, position) {
+		return nil
+	}
+	var children []ast.Node
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		for _, value := range n.Values {
+			children = append(children, value)
+		}
+	case *ast.MappingValueNode:
+		children = []ast.Node{n.Key, n.Value}
+	case *ast.SequenceNode:
+		children = n.Values
+	case *ast.AnchorNode:
+		children = []ast.Node{n.Value}
+	case *ast.TagNode:
+		children = []ast.Node{n.Value}
+	}
+	for _, child := range children {
+		if chain := selectionChain(child, position); chain != nil {
+			return append([]protocol.Range{r}, chain...)
+		}
+	}
+	return []protocol.Range{r}
+}
+
+// Return the position of the first character of a node, relative to the document
+func yamlNodeStart(node ast.Node) protocol.Position {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		if !n.IsFlowStyle && len(n.Values) > 0 {
+			return yamlNodeStart(n.Values[0])
+		}
+	case *ast.MappingValueNode:
+		return yamlNodeStart(n.Key)
+	}
+	return tokenPosition(node.GetToken())
+}
+
+// Return the position after the last character of a node, relative to the document. Implicit nulls,
+// like the value of `key:`, have no position.
+func yamlNodeEnd(node ast.Node) (protocol.Position, bool) {
+	switch n := node.(type) {
+	case nil:
+		return protocol.Position{}, false
+	case *ast.MappingNode:
+		if n.IsFlowStyle {
+			return tokenEnd(n.End), true
+		}
+		if len(n.Values) > 0 {
+			return yamlNodeEnd(n.Values[len(n.Values)-1])
+		}
+	case *ast.MappingValueNode:
+		if end, ok := yamlNodeEnd(n.Value); ok {
+			return end, true
+		}
+		// After the `:`
+		return tokenEnd(n.Start), true
+	case *ast.SequenceNode:
+		if n.IsFlowStyle {
+			return tokenEnd(n.End), true
+		}
+		if len(n.Values) > 0 {
+			if end, ok := yamlNodeEnd(n.Values[len(n.Values)-1]); ok {
+				return end, true
+			}
+		}
+		return tokenEnd(n.Start), true
+	case *ast.LiteralNode:
+		content := strings.TrimRight(n.Value.GetToken().Origin, " \n")
+		if content == "" {
+			return tokenEnd(n.Start), true
+		}
+		// The content starts on the line after the header
+		lines := strings.Split(content, "\n")
+		start := tokenPosition(n.Start)
+		return protocol.Position{Line: start.Line + uint32(len(lines)), Character: uint32(len(lines[len(lines)-1]))}, true
+	case *ast.AnchorNode:
+		return yamlNodeEnd(n.Value)
+	case *ast.TagNode:
+		if end, ok := yamlNodeEnd(n.Value); ok {
+			return end, true
+		}
+		return tokenEnd(n.Start), true
+	case *ast.AliasNode:
+		return yamlNodeEnd(n.Value)
+	case *ast.NullNode:
+		if n.Token.Type == token.ImplicitNullType {
+			return protocol.Position{}, false
+		}
+	}
+	return tokenEnd(node.GetToken()), true
+}
+
+func tokenPosition(t *token.Token) protocol.Position {
+	return protocol.Position{Line: uint32(t.Position.Line - 1), Character: uint32(t.Position.Column - 1)}
+}
+
+// Return the position after a token. Multi-line scalars end on a later line, where only the text of the
+// last line counts.
+func tokenEnd(t *token.Token) protocol.Position {
+	position := tokenPosition(t)
+	text := strings.TrimSpace(t.Origin)
+	i := strings.LastIndex(text, "\n")
+	if i == -1 {
+		position.Character += uint32(len(text))
+		return position
+	}
+	// The origin of the last line has the indentation
+	position.Line += uint32(strings.Count(text, "\n"))
+	position.Character = uint32(len(strings.TrimRight(text[i+1:], " \t")))
+	return position
+}
+
+// Return the first and last lines between `start` and `end` that aren't blank, or -1 if there are none
+func contentLines(lines []string, start, end int) (int, int) {
+	first, last := -1, -1
+	for i := start; i < end && i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		if first == -1 {
+			first = i
+		}
+		last = i
+	}
+	return first, last
+}
+
+func rangeContains(r protocol.Range, position protocol.Position) bool {
+	before := func(a, b protocol.Position) bool {
+		return a.Line < b.Line || a.Line == b.Line && a.Character <= b.Character
+	}
+	return before(r.Start, position) && before(position, r.End)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestFoldingRanges(t *testing.T) {
+	file := `# A service
+# and a deployment
+kind: Service
+spec:
+  ports: [80]
+
+---
+kind: Deployment
+spec:
+  containers:
+  - name: a
+    args:
+    - run
+  - name: b
+    command: |
+      echo hello
+
+      echo bye
+`
+	var actual []string
+	for _, r := range foldingRanges(file) {
+		actual = append(actual, fmt.Sprintf("%d-%d %s", r.StartLine, r.EndLine, r.Kind))
+	}
+	expected := []string{
+		"0-1 comment",
+		"3-4 ",
+		"6-17 region",
+		"8-17 ",
+		"9-17 ",
+		"10-12 ",
+		"11-12 ",
+		"13-17 ",
+		"14-17 ",
+	}
+	if !slices.Equal(actual, expected) {
+		t.Fatalf("expected\n%v\ngot\n%v", expected, actual)
+	}
+	if ranges := foldingRanges("kind: [\n"); len(ranges) != 0 {
+		t.Fatalf("expected no ranges for a one line document, got %v", ranges)
+	}
+}
+
+func TestSelectionRanges(t *testing.T) {
+	file := `kind: Service
+---
+kind: Deployment
+spec:
+  containers:
+  - name: a
+    args: ["run", "it"]
+`
+	tests := map[string]struct {
+		line, char int
+		expected   []string
+	}{
+		"scalar": {
+			line: 5,
+			char: 11,
+			expected: []string{
+				"5:10-5:11",
+				"5:4-5:11",
+				"5:4-6:23",
+				"5:2-6:23",
+				"4:2-6:23",
+				"3:0-6:23",
+				"2:0-6:23",
+			},
+		},
+		"flow": {
+			line: 6,
+			char: 13,
+			expected: []string{
+				"6:11-6:16",
+				"6:10-6:23",
+				"6:4-6:23",
+				"5:4-6:23",
+				"5:2-6:23",
+				"4:2-6:23",
+				"3:0-6:23",
+				"2:0-6:23",
+			},
+		},
+		"key": {
+			line: 0,
+			char: 1,
+			expected: []string{
+				"0:0-0:4",
+				"0:0-0:13",
+			},
+		},
+		"separator": {
+			line:     1,
+			char:     0,
+			expected: []string{"1:0-1:0"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			selections := selectionRanges(file, []protocol.Position{{Line: uint32(test.line), Character: uint32(test.char)}})
+			var actual []string
+			for s := &selections[0]; s != nil; s = s.Parent {
+				actual = append(actual, fmt.Sprintf("%d:%d-%d:%d", s.Range.Start.Line, s.Range.Start.Character, s.Range.End.Line, s.Range.End.Character))
+			}
+			if !slices.Equal(actual, test.expected) {
+				t.Fatalf("expected\n%v\ngot\n%v", test.expected, actual)
+			}
+		})
+	}
+}
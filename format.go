@@ -0,0 +1,441 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	yamlparser "github.com/goccy/go-yaml/parser"
+	"github.com/goccy/go-yaml/token"
+	"github.com/tidwall/gjson"
+)
+
+// The keys that come first when sorting keys, the rest are sorted alphabetically
+var formatKeyOrder = []string{"apiVersion", "kind", "metadata", "spec", "data"}
+
+type FormatOptions struct {
+	// The number of spaces to indent with, detected from the file if 0
+	indent int
+	// Sort the keys of objects with properties in the schema, and the top level keys of documents
+	// without a schema
+	sortKeys bool
+}
+
+// Set from the config when the language server is initialized
+var FORMAT_OPTIONS = FormatOptions{}
+
+// Format all documents in `file`. Indentation and quoting are normalized, comments and single blank
+// lines between entries are kept.
+func formatFile(file string, options FormatOptions) (string, error) {
+	lines := strings.Split(file, "\n")
+	style := formatStyle(lines, options)
+	var documents []string
+	for _, doc := range documentsInFile(file) {
+		formatted, err := formatDocument(lines[doc.start:doc.end], style, options.sortKeys)
+		if err != nil {
+			return "", fmt.Errorf("format document on line %d: %s", doc.start+1, err)
+		}
+		if formatted != "" {
+			documents = append(documents, formatted)
+		}
+	}
+	formatted := strings.Join(documents, "---\n")
+	if isDocumentSeparator(lines[0]) {
+		formatted = "---\n" + formatted
+	}
+	return formatted, nil
+}
+
+// Return the edits that format the documents overlapping the lines from `start` to `end`, inclusive
+func formatRangeEdits(file string, start, end int, options FormatOptions) ([]TextEdit, error) {
+	lines := strings.Split(file, "\n")
+	style := formatStyle(lines, options)
+	var edits []TextEdit
+	for _, doc := range documentsInFile(file) {
+		if doc.end <= start || end < doc.start {
+			continue
+		}
+		formatted, err := formatDocument(lines[doc.start:doc.end], style, options.sortKeys)
+		if err != nil {
+			return nil, fmt.Errorf("format document on line %d: %s", doc.start+1, err)
+		}
+		if formatted == "" || formatted == doc.document {
+			continue
+		}
+		if doc.end < len(lines) {
+			edits = append(edits, TextEdit{Range: newRange(doc.start, 0, doc.end, 0), NewText: formatted})
+		} else {
+			last := len(lines) - 1
+			edits = append(edits, TextEdit{Range: newRange(doc.start, 0, last, len(lines[last])), NewText: formatted})
+		}
+	}
+	return edits, nil
+}
+
+func formatStyle(lines []string, options FormatOptions) YamlStyle {
+	style := detectYamlStyle(lines)
+	if options.indent > 0 {
+		style.indent = options.indent
+	}
+	return style
+}
+
+type yamlFormatter struct {
+	b        strings.Builder
+	lines    []string
+	style    YamlStyle
+	sortKeys bool
+}
+
+// Return the formatted document, or an empty string if it is empty
+func formatDocument(lines []string, style YamlStyle, sortKeys bool) (string, error) {
+	doc := strings.Join(lines, "\n")
+	if strings.TrimSpace(doc) == "" {
+		return "", nil
+	}
+	file, err := yamlparser.ParseBytes([]byte(doc), yamlparser.ParseComments, yamlparser.AllowDuplicateMapKey())
+	if err != nil {
+		return "", err
+	}
+	if len(file.Docs) != 1 {
+		return "", fmt.Errorf("expected 1 document, got %d", len(file.Docs))
+	}
+	f := yamlFormatter{lines: lines, style: style, sortKeys: sortKeys}
+	var schema gjson.Result
+	if b, ok := documentSchema(doc); sortKeys && ok {
+		schema = gjson.ParseBytes(b)
+	}
+	switch body := file.Docs[0].Body.(type) {
+	case nil:
+		// Only comments
+		for _, line := range lines {
+			if trimmed := strings.TrimRight(line, " \t"); trimmed != "" {
+				f.b.WriteString(trimmed + "\n")
+			}
+		}
+	case *ast.MappingNode:
+		if body.IsFlowStyle {
+			f.b.WriteString(body.String() + "\n")
+			break
+		}
+		f.writeMapping(body.Values, body.FootComment, 0, false, schema, true)
+	case *ast.MappingValueNode:
+		f.writeMapping([]*ast.MappingValueNode{body}, body.FootComment, 0, false, schema, true)
+	case *ast.SequenceNode:
+		if body.IsFlowStyle {
+			f.b.WriteString(body.String() + "\n")
+			break
+		}
+		f.writeSequence(body, 0, false, schema)
+	default:
+		f.b.WriteString(strings.TrimSpace(body.String()) + "\n")
+	}
+	return f.b.String(), nil
+}
+
+// Write the entries of a block mapping at `indent`. If `inline` is true, the first key is written on the
+// current line, after the `- ` of a sequence entry.
+func (f *yamlFormatter) writeMapping(values []*ast.MappingValueNode, foot *ast.CommentGroupNode, indent int, inline bool, schema gjson.Result, root bool) {
+	sorted := f.sortedValues(values, schema, root)
+	// The first comment of a document stays at the top
+	var documentComment *ast.CommentGroupNode
+	if root && sorted[0] != values[0] {
+		documentComment = values[0].GetComment()
+		f.writeComments(documentComment, indent)
+	}
+	values = sorted
+	for i, value := range values {
+		if !inline || i > 0 {
+			head := value.GetComment()
+			if head == documentComment {
+				head = nil
+			}
+			if i > 0 {
+				f.writeBlankLineBefore(value.Key.GetToken().Position.Line, head)
+			}
+			f.writeComments(head, indent)
+			f.b.WriteString(strings.Repeat(" ", indent))
+		}
+		key := value.Key.GetToken().Value
+		f.b.WriteString(formatKey(value.Key) + ":")
+		f.writeValue(value.Value, indent, lineComment(value.Key), schema.Get("properties").Get(gjson.Escape(key)))
+		f.writeComments(value.FootComment, indent)
+	}
+	f.writeComments(foot, indent)
+}
+
+// Write the entries of a block sequence at `indent`
+func (f *yamlFormatter) writeSequence(sequence *ast.SequenceNode, indent int, inline bool, schema gjson.Result) {
+	items := schema.Get("items")
+	for i, item := range sequence.Values {
+		if !inline || i > 0 {
+			var head *ast.CommentGroupNode
+			if i < len(sequence.ValueHeadComments) {
+				head = sequence.ValueHeadComments[i]
+			}
+			if i == 0 && head == nil {
+				head = sequence.GetComment()
+			}
+			if i > 0 {
+				f.writeBlankLineBefore(item.GetToken().Position.Line, head)
+			}
+			f.writeComments(head, indent)
+			// The head comment of the first key in the entry comes before the `-`
+			if mapping, ok := item.(*ast.MappingNode); ok && !mapping.IsFlowStyle && len(mapping.Values) > 0 {
+				f.writeComments(f.sortedValues(mapping.Values, items, false)[0].GetComment(), indent)
+			}
+			f.b.WriteString(strings.Repeat(" ", indent))
+		}
+		f.b.WriteString("-")
+		switch item := item.(type) {
+		case *ast.MappingNode:
+			if item.IsFlowStyle || len(item.Values) == 0 {
+				f.writeValue(item, indent, "", items)
+				break
+			}
+			f.b.WriteString(" ")
+			f.writeMapping(item.Values, item.FootComment, indent+2, true, items, false)
+		case *ast.MappingValueNode:
+			f.b.WriteString(" ")
+			f.writeMapping([]*ast.MappingValueNode{item}, item.FootComment, indent+2, true, items, false)
+		case *ast.SequenceNode:
+			if item.IsFlowStyle || len(item.Values) == 0 {
+				f.writeValue(item, indent, "", items)
+				break
+			}
+			f.b.WriteString(" ")
+			f.writeSequence(item, indent+2, true, items)
+		default:
+			f.writeValue(item, indent, "", items)
+		}
+	}
+	f.writeComments(sequence.FootComment, indent)
+}
+
+// Write the value of a key at `indent`, or of a sequence entry at `indent`, starting after the `:` or
+// `-`. `comment` is the line comment of the key, which comes after the key when the value is a block.
+func (f *yamlFormatter) writeValue(value ast.Node, indent int, comment string, schema gjson.Result) {
+	switch value := value.(type) {
+	case nil:
+		f.b.WriteString(comment + "\n")
+	case *ast.NullNode:
+		if comment == "" {
+			comment = lineComment(value)
+		}
+		if value.GetToken().Type == token.ImplicitNullType {
+			f.b.WriteString(comment + "\n")
+			break
+		}
+		f.b.WriteString(" " + value.GetToken().Value + comment + "\n")
+	case *ast.MappingNode:
+		if value.IsFlowStyle || len(value.Values) == 0 {
+			f.b.WriteString(" " + value.String() + f.flowComment(value.End, comment) + "\n")
+			break
+		}
+		f.b.WriteString(comment + "\n")
+		f.writeMapping(value.Values, value.FootComment, indent+f.style.indent, false, schema, false)
+	case *ast.MappingValueNode:
+		f.b.WriteString(comment + "\n")
+		f.writeMapping([]*ast.MappingValueNode{value}, value.FootComment, indent+f.style.indent, false, schema, false)
+	case *ast.SequenceNode:
+		if value.IsFlowStyle || len(value.Values) == 0 {
+			f.b.WriteString(" " + value.String() + f.flowComment(value.End, comment) + "\n")
+			break
+		}
+		f.b.WriteString(comment + "\n")
+		if f.style.indentSequences {
+			indent += f.style.indent
+		}
+		f.writeSequence(value, indent, false, schema)
+	case *ast.LiteralNode:
+		f.writeBlockScalar(value, indent+f.style.indent, comment)
+	case *ast.AnchorNode:
+		f.b.WriteString(" &" + value.Name.GetToken().Value)
+		f.writeValue(value.Value, indent, comment, schema)
+	case *ast.TagNode:
+		f.b.WriteString(" " + value.Start.Value)
+		f.writeValue(value.Value, indent, comment, schema)
+	case *ast.AliasNode:
+		if comment == "" {
+			comment = lineComment(value.Value)
+		}
+		f.b.WriteString(" " + formatScalar(value) + comment + "\n")
+	default:
+		if comment == "" {
+			comment = lineComment(value)
+		}
+		f.b.WriteString(" " + formatScalar(value) + comment + "\n")
+	}
+}
+
+// Write a `|` or `>` scalar with its content at `indent`. The content is taken from the original lines,
+// since the value of a folded scalar is already folded.
+func (f *yamlFormatter) writeBlockScalar(literal *ast.LiteralNode, indent int, comment string) {
+	header := literal.Start.Value
+	content := strings.Split(strings.TrimSuffix(literal.Value.GetToken().Origin, "\n"), "\n")
+	value := strings.Split(literal.Value.Value, "\n")
+	if !strings.Contains(header, "+") {
+		for len(content) > 0 && strings.TrimSpace(content[len(content)-1]) == "" {
+			content = content[:len(content)-1]
+		}
+	}
+	// Lines that start with spaces need an indentation indicator, `|2`, the content is indented by the
+	// indicator, and the rest of the spaces are part of the value
+	base := 0
+	for _, line := range content {
+		if strings.TrimSpace(line) != "" {
+			base = yamlSpaces(line)
+			break
+		}
+	}
+	for _, line := range value {
+		if strings.TrimSpace(line) != "" {
+			base -= yamlSpaces(line)
+			break
+		}
+	}
+	if i := strings.IndexAny(header, "123456789"); i != -1 && f.style.indent < 10 {
+		header = header[:i] + strconv.Itoa(f.style.indent) + header[i+1:]
+	}
+	f.b.WriteString(" " + header + comment + "\n")
+	for _, line := range content {
+		if len(line) <= base || strings.TrimSpace(line[:base]) != "" {
+			f.b.WriteString("\n")
+			continue
+		}
+		f.b.WriteString(strings.Repeat(" ", indent) + line[base:] + "\n")
+	}
+}
+
+// The parser drops the comments after flow collections, they are taken from the original line instead
+func (f *yamlFormatter) flowComment(end *token.Token, comment string) string {
+	if comment != "" || end == nil || end.Position.Line > len(f.lines) {
+		return comment
+	}
+	line := f.lines[end.Position.Line-1]
+	if end.Position.Column > len(line) {
+		return ""
+	}
+	rest := strings.TrimSpace(line[end.Position.Column:])
+	if !strings.HasPrefix(rest, "#") {
+		return ""
+	}
+	return " " + rest
+}
+
+// Keep one blank line before a node on `line`, or before its head comment, if there was at least one
+// in the original. A `|+` scalar before the node already wrote its trailing blank lines, which are part
+// of its value.
+func (f *yamlFormatter) writeBlankLineBefore(line int, head *ast.CommentGroupNode) {
+	if head != nil && len(head.Comments) > 0 {
+		line = min(line, head.Comments[0].GetToken().Position.Line)
+	}
+	if strings.HasSuffix(f.b.String(), "\n\n") {
+		return
+	}
+	if line >= 2 && line-2 < len(f.lines) && strings.TrimSpace(f.lines[line-2]) == "" {
+		f.b.WriteString("\n")
+	}
+}
+
+func (f *yamlFormatter) writeComments(comments *ast.CommentGroupNode, indent int) {
+	if comments == nil {
+		return
+	}
+	for _, comment := range comments.Comments {
+		f.b.WriteString(strings.Repeat(" ", indent) + "#" + strings.TrimRight(comment.GetToken().Value, " \t") + "\n")
+	}
+}
+
+// Return the comment on the same line as `node`, with a space before it
+func lineComment(node ast.Node) string {
+	comment := node.GetComment()
+	if comment == nil || len(comment.Comments) == 0 {
+		return ""
+	}
+	return " #" + strings.TrimRight(comment.Comments[0].GetToken().Value, " \t")
+}
+
+// Sort the keys of objects with properties in the schema, and the top level keys of documents without
+// a schema
+func (f *yamlFormatter) sortedValues(values []*ast.MappingValueNode, schema gjson.Result, root bool) []*ast.MappingValueNode {
+	if !f.sortKeys || !schema.Get("properties").IsObject() && !(root && !schema.Exists()) {
+		return values
+	}
+	values = slices.Clone(values)
+	slices.SortStableFunc(values, func(a, b *ast.MappingValueNode) int {
+		keyA, keyB := a.Key.GetToken().Value, b.Key.GetToken().Value
+		if rankA, rankB := formatKeyRank(keyA), formatKeyRank(keyB); rankA != rankB {
+			return rankA - rankB
+		}
+		return strings.Compare(keyA, keyB)
+	})
+	return values
+}
+
+func formatKeyRank(key string) int {
+	if i := slices.Index(formatKeyOrder, key); i != -1 {
+		return i
+	}
+	return len(formatKeyOrder)
+}
+
+func formatKey(key ast.MapKeyNode) string {
+	if key, ok := key.(*ast.StringNode); ok {
+		if key.Value == "<<" {
+			return `"<<"`
+		}
+		return formatScalar(key)
+	}
+	return strings.TrimSpace(key.String())
+}
+
+// Strings are written without quotes when that doesn't change their value, other scalars as they are
+func formatScalar(node ast.Node) string {
+	t := node.GetToken()
+	if alias, ok := node.(*ast.AliasNode); ok {
+		return "*" + alias.Value.GetToken().Value
+	}
+	s, ok := node.(*ast.StringNode)
+	if !ok {
+		return t.Value
+	}
+	if t.Type != token.SingleQuoteType && t.Type != token.DoubleQuoteType {
+		return t.Value
+	}
+	if strings.Contains(strings.TrimSpace(t.Origin), "\n") {
+		return strings.TrimSpace(t.Origin)
+	}
+	return quoteString(s.Value)
+}
+
+func quoteString(s string) string {
+	b, err := yaml.Marshal(s)
+	text := strings.TrimSuffix(string(b), "\n")
+	if err != nil || strings.Contains(text, "\n") {
+		return strconv.Quote(s)
+	}
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		return text
+	}
+	// Some strings are marshaled without quotes even though other parsers read them as other types,
+	// like `1e3` and `.inf`
+	if _, err := strconv.ParseFloat(s, 64); err == nil || slices.Contains(yaml11Keywords, strings.ToLower(s)) {
+		return strconv.Quote(s)
+	}
+	var decoded map[string]any
+	if err := yaml.Unmarshal([]byte("v: "+text), &decoded); err != nil || decoded["v"] != s {
+		return strconv.Quote(s)
+	}
+	return text
+}
+
+// Scalars that aren't strings in yaml 1.1
+var yaml11Keywords = []string{"y", "n", "yes", "no", "on", "off", "true", "false", "null", "~", ".inf", "-.inf", "+.inf", ".nan"}
+
+func yamlSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+func TestFormatFile(t *testing.T) {
+	useTestSchemas(t, map[string][]byte{"Thing_example.com_v1.json": quickFixSchema})
+
+	tests := map[string]struct {
+		file     string
+		options  FormatOptions
+		expected string
+	}{
+		"indentation": {
+			file: `spec:
+    image: nginx
+    ports:
+        -   containerPort: 80
+            protocol: TCP
+`,
+			options: FormatOptions{indent: 2},
+			expected: `spec:
+  image: nginx
+  ports:
+    - containerPort: 80
+      protocol: TCP
+`,
+		},
+		"quotes": {
+			file: `name: "web"
+port: "80"
+enabled: 'yes'
+exponent: "1e3"
+quote: 'it''s'
+colon: "a: b"
+"app.kubernetes.io/name": web
+`,
+			expected: `name: web
+port: "80"
+enabled: "yes"
+exponent: "1e3"
+quote: it's
+colon: "a: b"
+app.kubernetes.io/name: web
+`,
+		},
+		"comments": {
+			file: `# The service
+metadata: # metadata
+  # The name
+  name: web # web
+
+
+  labels:
+    app: web
+    # end of labels
+ports:
+# http
+- port: 80 # port
+# https
+- port: 443
+`,
+			expected: `# The service
+metadata: # metadata
+  # The name
+  name: web # web
+
+  labels:
+    app: web
+    # end of labels
+ports:
+# http
+- port: 80 # port
+# https
+- port: 443
+`,
+		},
+		"documents": {
+			file: `---
+kind: Service
+---
+
+
+---
+# Only comments
+---
+kind: Pod`,
+			expected: `---
+kind: Service
+---
+# Only comments
+---
+kind: Pod
+`,
+		},
+		"block-scalars": {
+			file: `data:
+    script: |
+        echo a
+          echo b
+
+    folded: >-
+        a
+        b
+    indented: |2
+          leading spaces
+`,
+			options: FormatOptions{indent: 2},
+			expected: `data:
+  script: |
+    echo a
+      echo b
+
+  folded: >-
+    a
+    b
+  indented: |2
+        leading spaces
+`,
+		},
+		"keep-chomping": {
+			file: `a: |+
+  keep
+
+b: 1
+`,
+			expected: `a: |+
+  keep
+
+b: 1
+`,
+		},
+		"anchors-and-flow": {
+			file: `base: &base {a: 1} # base
+list: [a, b]   # list
+other: *base # other
+`,
+			expected: `base: &base {a: 1} # base
+list: [a, b] # list
+other: *base # other
+`,
+		},
+		"sort-keys": {
+			file: `# A thing
+spec:
+  version: "1"
+  image: nginx
+  labels:
+    b: b
+    a: a
+kind: Thing
+apiVersion: example.com/v1
+`,
+			options: FormatOptions{sortKeys: true},
+			expected: `# A thing
+apiVersion: example.com/v1
+kind: Thing
+spec:
+  image: nginx
+  labels:
+    b: b
+    a: a
+  version: "1"
+`,
+		},
+		"sort-keys-without-schema": {
+			file: `spec:
+  b: b
+  a: a
+metadata:
+  name: web
+kind: Unknown
+`,
+			options: FormatOptions{sortKeys: true},
+			expected: `kind: Unknown
+metadata:
+  name: web
+spec:
+  b: b
+  a: a
+`,
+		},
+		"sort-keys-in-sequence": {
+			file: `apiVersion: example.com/v1
+kind: Thing
+spec:
+  ports:
+  - protocol: TCP
+    # The port
+    containerPort: 80
+`,
+			options: FormatOptions{sortKeys: true},
+			expected: `apiVersion: example.com/v1
+kind: Thing
+spec:
+  ports:
+  # The port
+  - containerPort: 80
+    protocol: TCP
+`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			formatted, err := formatFile(test.file, test.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if formatted != test.expected {
+				t.Fatalf("expected\n%s\ngot\n%s", test.expected, formatted)
+			}
+			again, err := formatFile(formatted, test.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if again != formatted {
+				t.Fatalf("expected formatting to be stable, got\n%s", again)
+			}
+		})
+	}
+	if _, err := formatFile("a: [b\n", FormatOptions{}); err == nil {
+		t.Fatalf("expected an error for invalid yaml")
+	}
+}
+
+func TestFormatFileKeepsValues(t *testing.T) {
+	tests := map[string]string{
+		"keep-chomping": `a: |+
+  keep
+
+b: 1
+`,
+		"keep-chomping-with-comment": `a: |+
+  keep
+
+
+# b
+b: 1
+`,
+		"keep-chomping-in-sequence": `- |+
+  keep
+
+- |-
+  strip
+
+- 1
+`,
+		"folded": `a: >
+  one
+  two
+
+  three
+b: |2
+    indented
+`,
+		"quotes": `a: "yes"
+b: '1e3'
+c: "it's"
+d: 'a: b'
+`,
+		"anchors": `base: &base
+  a: 1
+other: *base
+`,
+	}
+	for name, file := range tests {
+		t.Run(name, func(t *testing.T) {
+			formatted, err := formatFile(file, FormatOptions{indent: 4})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var before, after any
+			if err := yaml.Unmarshal([]byte(file), &before); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal([]byte(formatted), &after); err != nil {
+				t.Fatalf("expected the formatted file to be valid yaml, got %s:\n%s", err, formatted)
+			}
+			if !reflect.DeepEqual(before, after) {
+				t.Fatalf("expected the values to be kept, got %#v, expected %#v, formatted:\n%s", after, before, formatted)
+			}
+			again, err := formatFile(formatted, FormatOptions{indent: 4})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if again != formatted {
+				t.Fatalf("expected formatting to be stable, got\n%s\nthen\n%s", formatted, again)
+			}
+		})
+	}
+}
+
+func TestFormatRangeEdits(t *testing.T) {
+	file := "a:   1\n---\nb:    2\n---\nc:     3\n"
+	edits, err := formatRangeEdits(file, 2, 2, FormatOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := "a:   1\n---\nb: 2\n---\nc:     3\n"
+	if actual := applyTextEdits(file, edits); actual != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, actual)
+	}
+}
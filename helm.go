@@ -0,0 +1,185 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Helm templates contain Go template actions such as `{{ .Values.image }}` which makes them invalid yaml.
+// The actions are masked with placeholders of the same length so that positions in the masked file are
+// the same as in the original one, and errors on the masked spans are suppressed.
+
+const TEMPLATE_PLACEHOLDER = '_'
+
+var templateActionPattern = regexp.MustCompile(`(?s)\{\{.*?\}\}`)
+
+// Return true if `filename` is inside the `templates` directory of a Helm chart
+func isHelmTemplate(filename string) bool {
+	dir := filepath.Dir(filename)
+	for {
+		parent := filepath.Dir(dir)
+		if filepath.Base(dir) == "templates" {
+			if _, err := os.Stat(filepath.Join(parent, "Chart.yaml")); err == nil {
+				return true
+			}
+		}
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// Replace all template actions in `file` with placeholders. Lines that only contain template actions,
+// e.g. `{{- if .Values.enabled }}`, are replaced with spaces. Return the masked file and the masked ranges.
+func maskTemplateActions(file string) (string, []Range) {
+	masked := []byte(file)
+	lineStarts := []int{0}
+	for i, c := range masked {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	position := func(offset int) Position {
+		line := 0
+		for line+1 < len(lineStarts) && lineStarts[line+1] <= offset {
+			line++
+		}
+		return Position{Line: line, Char: offset - lineStarts[line]}
+	}
+
+	var masks []Range
+	maskedLines := map[int]bool{}
+	for _, match := range templateActionPattern.FindAllIndex(masked, -1) {
+		for i := match[0]; i < match[1]; i++ {
+			if masked[i] != '\n' {
+				masked[i] = TEMPLATE_PLACEHOLDER
+			}
+		}
+		start, end := position(match[0]), position(match[1])
+		for line := start.Line; line <= end.Line; line++ {
+			maskedLines[line] = true
+		}
+		masks = append(masks, Range{Start: start, End: end})
+	}
+
+	for line := range maskedLines {
+		start := lineStarts[line]
+		end := len(masked)
+		if line+1 < len(lineStarts) {
+			end = lineStarts[line+1] - 1
+		}
+		onlyActions := true
+		for _, c := range masked[start:end] {
+			if c != ' ' && c != '\t' && c != TEMPLATE_PLACEHOLDER {
+				onlyActions = false
+				break
+			}
+		}
+		if onlyActions {
+			for i := start; i < end; i++ {
+				masked[i] = ' '
+			}
+			masks = append(masks, newRange(line, 0, line, end-start))
+		}
+	}
+	return string(masked), masks
+}
+
+// Validate a file that contains template actions. Errors on lines with masked actions are dropped, as
+// well as errors that the template actions could have caused somewhere else in the document.
+func fileValidateTemplate(file string) ([]ValidationError, ValidationFailureReason) {
+	masked, masks := maskTemplateActions(file)
+	validationErrors, valFailure := fileValidate(masked)
+	if valFailure != VALIDATION_FAILURE_REASON_NOT_A_FAILURE || len(masks) == 0 {
+		return validationErrors, valFailure
+	}
+	documents := documentsInFile(masked)
+	lines := strings.Split(masked, "\n")
+	var result []ValidationError
+	for _, e := range validationErrors {
+		if masksOnLines(masks, e.Range.Start.Line, e.Range.End.Line) {
+			continue
+		}
+		doc, found := documentAtLine(documents, e.Range.Start.Line)
+		if found {
+			switch e.Type {
+			case "required":
+				// An included template could have provided the missing properties
+				if masksOnLines(masks, doc.start, doc.end) {
+					continue
+				}
+			case "invalid_type":
+				// A key whose value is a template, e.g. `{{- include "app.labels" . | nindent 4 }}` on the
+				// next line, is null when the template is masked
+				if maskedNullValue(lines, masks, e.Range.Start.Line) {
+					continue
+				}
+			case "no_schema_found":
+				// The kind or apiVersion could be templated
+				maskedGVK := false
+				for _, line := range kindAndApiVersionLines(doc) {
+					maskedGVK = maskedGVK || masksOnLines(masks, line, line)
+				}
+				if maskedGVK {
+					continue
+				}
+			}
+		}
+		result = append(result, e)
+	}
+	return result, valFailure
+}
+
+// Return true if any of the masks is on a line between `start` and `end`, inclusive
+func masksOnLines(masks []Range, start, end int) bool {
+	for _, m := range masks {
+		if m.Start.Line <= end && start <= m.End.Line {
+			return true
+		}
+	}
+	return false
+}
+
+// Return true if the key on `line` has no value of its own and the lines of its block are lines with only
+// template actions, which were masked with spaces
+func maskedNullValue(lines []string, masks []Range, line int) bool {
+	if line >= len(lines) || !strings.HasSuffix(strings.TrimSpace(lines[line]), ":") {
+		return false
+	}
+	indent := yamlSpaces(lines[line])
+	masked := false
+	for i := line + 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			// A sequence can be at the same indentation as its key
+			if yamlSpaces(lines[i]) > indent || yamlSpaces(lines[i]) == indent && strings.HasPrefix(strings.TrimSpace(lines[i]), "-") {
+				return false
+			}
+			break
+		}
+		masked = masked || masksOnLines(masks, i, i)
+	}
+	return masked
+}
+
+func documentAtLine(documents []DocumentPosition, line int) (DocumentPosition, bool) {
+	for _, doc := range documents {
+		if doc.start <= line && line <= doc.end {
+			return doc, true
+		}
+	}
+	return DocumentPosition{}, false
+}
+
+// Return the lines in the file where `kind` and `apiVersion` are set
+func kindAndApiVersionLines(doc DocumentPosition) []int {
+	var lines []int
+	for i, line := range strings.Split(doc.document, "\n") {
+		if strings.HasPrefix(line, "kind:") || strings.HasPrefix(line, "apiVersion:") {
+			lines = append(lines, doc.start+i)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	_ "embed"
+	"slices"
+	"strings"
+	"testing"
+)
+
+//go:embed testdata/service-v1.json
+var serviceV1 []byte
+
+func TestMaskTemplateActions(t *testing.T) {
+	tests := map[string]struct {
+		file, masked string
+		masks        []Range
+	}{
+		"no-actions": {
+			file: `kind: Service
+`,
+			masked: `kind: Service
+`,
+		},
+		"value": {
+			file: `name: {{ .Release.Name }}-app
+`,
+			masked: `name: ___________________-app
+`,
+			masks: []Range{newRange(0, 6, 0, 25)},
+		},
+		"control-line": {
+			file: `spec:
+  {{- if .Values.enabled }}
+  type: ClusterIP
+  {{- end }}
+`,
+			masked: "spec:\n" + strings.Repeat(" ", 27) + "\n  type: ClusterIP\n" + strings.Repeat(" ", 12) + "\n",
+			masks: []Range{
+				newRange(1, 2, 1, 27),
+				newRange(3, 2, 3, 12),
+				newRange(1, 0, 1, 27),
+				newRange(3, 0, 3, 12),
+			},
+		},
+		"multi-line": {
+			file: `{{/*
+comment
+*/}}
+kind: Service
+`,
+			masked: "    \n       \n    \nkind: Service\n",
+			masks: []Range{
+				newRange(0, 0, 2, 4),
+				newRange(0, 0, 0, 4),
+				newRange(1, 0, 1, 7),
+				newRange(2, 0, 2, 4),
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			masked, masks := maskTemplateActions(test.file)
+			if masked != test.masked {
+				t.Fatalf("expected\n`%s`\ngot\n`%s`", test.masked, masked)
+			}
+			sortRanges := func(a, b Range) int {
+				if a.Start.Line != b.Start.Line {
+					return a.Start.Line - b.Start.Line
+				}
+				return a.Start.Char - b.Start.Char
+			}
+			slices.SortFunc(masks, sortRanges)
+			slices.SortFunc(test.masks, sortRanges)
+			if !slices.Equal(masks, test.masks) {
+				t.Fatalf("expected masks %v, got %v", test.masks, masks)
+			}
+		})
+	}
+}
+
+func TestFileValidateTemplate(t *testing.T) {
+	schemaCache["Service_v1.json"] = serviceV1
+	tests := map[string]struct {
+		contents string
+		errors   []ValidationError
+	}{
+		"valid": {
+			contents: `kind: Service
+apiVersion: v1
+metadata:
+  name: {{ .Release.Name }}
+  {{- with .Values.labels }}
+  labels:
+    {{- toYaml . | nindent 4 }}
+  {{- end }}
+spec:
+  ports:
+    - port: {{ .Values.port }}
+`,
+			errors: nil,
+		},
+		"error-outside-action": {
+			contents: `kind: Service
+apiVersion: v1
+metadata:
+  name: {{ .Release.Name }}
+spec:
+  hej: du
+`,
+			errors: []ValidationError{
+				{
+					Range: newRange(5, 2, 5, 5),
+					Type:  "additional_property_not_allowed",
+				},
+			},
+		},
+		"templated-api-version": {
+			contents: `kind: Ingress
+apiVersion: {{ include "ingress.apiVersion" . }}
+`,
+			errors: nil,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			errors, fail := fileValidateTemplate(test.contents)
+			if fail != VALIDATION_FAILURE_REASON_NOT_A_FAILURE {
+				t.Fatalf("expected validation to work, got %s", fail)
+			}
+			if len(errors) != len(test.errors) {
+				t.Fatalf("expected %d errors, got %v", len(test.errors), errors)
+			}
+			for i := range errors {
+				if errors[i].Type != test.errors[i].Type {
+					t.Fatalf("expected type `%s`, got `%s`", test.errors[i].Type, errors[i].Type)
+				}
+				if errors[i].Range != test.errors[i].Range {
+					t.Fatalf("expected range %v, got %v", test.errors[i].Range, errors[i].Range)
+				}
+			}
+		})
+	}
+}
+
+func TestFileValidateHelmCreateService(t *testing.T) {
+	previous, cached := schemaCache["Service_v1.json"]
+	schemaCache["Service_v1.json"] = []byte(`{
+  "type": "object",
+  "properties": {
+    "apiVersion": {"type": "string"},
+    "kind": {"type": "string"},
+    "metadata": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "labels": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    },
+    "spec": {
+      "type": "object",
+      "properties": {
+        "type": {"type": "string"},
+        "ports": {"type": "array", "items": {"type": "object"}},
+        "selector": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    }
+  }
+}`)
+	t.Cleanup(func() {
+		if cached {
+			schemaCache["Service_v1.json"] = previous
+		} else {
+			delete(schemaCache, "Service_v1.json")
+		}
+	})
+
+	tests := map[string]struct {
+		contents string
+		errors   []ValidationError
+	}{
+		// templates/service.yaml from `helm create app`
+		"helm-create": {
+			contents: `apiVersion: v1
+kind: Service
+metadata:
+  name: {{ include "app.fullname" . }}
+  labels:
+    {{- include "app.labels" . | nindent 4 }}
+spec:
+  type: {{ .Values.service.type }}
+  ports:
+    - port: {{ .Values.service.port }}
+      targetPort: http
+      protocol: TCP
+      name: http
+  selector:
+    {{- include "app.selectorLabels" . | nindent 4 }}
+`,
+			errors: nil,
+		},
+		"null-without-template": {
+			contents: `apiVersion: v1
+kind: Service
+metadata:
+  name: {{ include "app.fullname" . }}
+  labels:
+spec:
+  selector:
+    {{- include "app.selectorLabels" . | nindent 4 }}
+`,
+			errors: []ValidationError{
+				{
+					Range: newRange(4, 2, 4, 8),
+					Type:  "invalid_type",
+				},
+			},
+		},
+		"template-and-value": {
+			contents: `apiVersion: v1
+kind: Service
+metadata:
+  name: {{ include "app.fullname" . }}
+spec:
+  type:
+    {{- include "app.labels" . | nindent 4 }}
+    app: web
+`,
+			errors: []ValidationError{
+				{
+					Range: newRange(5, 2, 5, 6),
+					Type:  "invalid_type",
+				},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			errors, fail := fileValidateTemplate(test.contents)
+			if fail != VALIDATION_FAILURE_REASON_NOT_A_FAILURE {
+				t.Fatalf("expected validation to work, got %s", fail)
+			}
+			if len(errors) != len(test.errors) {
+				t.Fatalf("expected %d errors, got %v", len(test.errors), errors)
+			}
+			for i := range errors {
+				if errors[i].Type != test.errors[i].Type {
+					t.Fatalf("expected type `%s`, got `%s`", test.errors[i].Type, errors[i].Type)
+				}
+				if errors[i].Range != test.errors[i].Range {
+					t.Fatalf("expected range %v, got %v", test.errors[i].Range, errors[i].Range)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/tidwall/gjson"
+	"go.lsp.dev/protocol"
+)
+
+// The protocol package is older than inlay hints, which were added in LSP 3.17
+const METHOD_TEXT_DOCUMENT_INLAY_HINT = "textDocument/inlayHint"
+
+type InlayHintParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+	Range        protocol.Range                  `json:"range"`
+}
+
+type InlayHintKind int
+
+const (
+	INLAY_HINT_KIND_TYPE      InlayHintKind = 1
+	INLAY_HINT_KIND_PARAMETER InlayHintKind = 2
+)
+
+type InlayHint struct {
+	Position    protocol.Position `json:"position"`
+	Label       string            `json:"label"`
+	Kind        InlayHintKind     `json:"kind,omitempty"`
+	PaddingLeft bool              `json:"paddingLeft,omitempty"`
+}
+
+// The maximum number of defaults in a hint, the rest are left out
+const MAX_INLAY_HINT_DEFAULTS = 5
+
+// The maximum number of `$ref`s to follow to a schema
+const MAX_REF_DEPTH = 8
+
+type inlayHinter struct {
+	lines      []string
+	root       gjson.Result // the schema of the document, to resolve `$ref`s
+	firstLine  int          // the line of the document in the file
+	start, end int          // the lines to return hints for, inclusive
+	hints      []InlayHint
+}
+
+// Return the hints for the lines from `start` to `end` in `file`. Scalar values get their type, mappings
+// the defaults of their missing properties and objects the name of the definition they refer to.
+func inlayHints(file string, start, end int) []InlayHint {
+	lines := strings.Split(file, "\n")
+	h := inlayHinter{lines: lines, start: start, end: end}
+	for _, doc := range documentsInFile(file) {
+		if doc.end <= start || end < doc.start {
+			continue
+		}
+		body, err := parseYamlDocument(doc.document)
+		if err != nil || body == nil {
+			continue
+		}
+		schema, ok := documentSchema(doc.document)
+		if !ok {
+			continue
+		}
+		h.root = gjson.ParseBytes(schema)
+		h.firstLine = doc.start
+		h.visit(body, h.root, -1)
+	}
+	slices.SortStableFunc(h.hints, func(a, b InlayHint) int {
+		if a.Position.Line != b.Position.Line {
+			return int(a.Position.Line) - int(b.Position.Line)
+		}
+		return int(a.Position.Character) - int(b.Position.Character)
+	})
+	return h.hints
+}
+
+// Visit a node at `schema`. The defaults of a mapping are shown at the end of `line`, relative to the
+// document, or not at all if it is -1.
+func (h *inlayHinter) visit(node ast.Node, schema gjson.Result, line int) {
+	schema, _ = h.resolve(schema)
+	switch n := unwrapYamlNode(node).(type) {
+	case *ast.MappingNode:
+		h.visitMapping(n.Values, schema, line)
+	case *ast.MappingValueNode:
+		h.visitMapping([]*ast.MappingValueNode{n}, schema, line)
+	case *ast.SequenceNode:
+		for _, item := range n.Values {
+			h.visit(item, schema.Get("items"), item.GetToken().Position.Line-1)
+		}
+	}
+}
+
+func (h *inlayHinter) visitMapping(values []*ast.MappingValueNode, schema gjson.Result, line int) {
+	properties := schema.Get("properties")
+	for _, value := range values {
+		keyToken := value.Key.GetToken()
+		property, ref := h.resolve(properties.Get(gjson.Escape(keyToken.Value)))
+		if !property.Exists() {
+			continue
+		}
+		switch v := unwrapYamlNode(value.Value).(type) {
+		case *ast.MappingNode, *ast.MappingValueNode, *ast.SequenceNode:
+			if _, items := h.resolve(property.Get("items")); ref == "" && items != "" {
+				ref = "[]" + items
+			}
+			if ref != "" {
+				// After the `:`
+				h.add(keyToken.Position.Line-1, keyToken.Position.Column+len(strings.TrimSpace(keyToken.Origin)), ref, INLAY_HINT_KIND_TYPE, true)
+			}
+			h.visit(v, property, keyToken.Position.Line-1)
+		case *ast.NullNode, nil:
+		default:
+			schemaMap, _ := property.Value().(map[string]any)
+			t := v.GetToken()
+			text := strings.TrimSpace(t.Origin)
+			if typeName := schemaTypeName(schemaMap); typeName != "" && !strings.Contains(text, "\n") {
+				h.add(t.Position.Line-1, t.Position.Column-1+len(text), ": "+typeName, INLAY_HINT_KIND_TYPE, false)
+			}
+		}
+	}
+	// After the type of a value on the same line
+	if line >= 0 {
+		h.addDefaults(values, properties, line)
+	}
+}
+
+// Show the properties with defaults that aren't set, as a flow mapping at the end of `line`
+func (h *inlayHinter) addDefaults(values []*ast.MappingValueNode, properties gjson.Result, line int) {
+	var defaults []string
+	properties.ForEach(func(key, property gjson.Result) bool {
+		property, _ = h.resolve(property)
+		def := property.Get("default")
+		if !def.Exists() || findMappingValue(values, key.String()) != nil {
+			return true
+		}
+		b, err := yaml.MarshalWithOptions(jsonValue(def), yaml.Flow(true))
+		if err != nil {
+			return true
+		}
+		defaults = append(defaults, fmt.Sprintf("%s: %s", key.String(), strings.TrimSpace(string(b))))
+		return true
+	})
+	if len(defaults) == 0 {
+		return
+	}
+	label := strings.Join(defaults[:min(len(defaults), MAX_INLAY_HINT_DEFAULTS)], ", ")
+	if len(defaults) > MAX_INLAY_HINT_DEFAULTS {
+		label += ", ..."
+	}
+	fileLine := h.firstLine + line
+	if fileLine >= len(h.lines) {
+		return
+	}
+	h.add(line, len(strings.TrimRight(h.lines[fileLine], " ")), "defaults: {"+label+"}", INLAY_HINT_KIND_PARAMETER, true)
+}
+
+// Add a hint at `line` and `char` in the document, if it is in the requested range
+func (h *inlayHinter) add(line, char int, label string, kind InlayHintKind, paddingLeft bool) {
+	line += h.firstLine
+	if line < h.start || h.end < line {
+		return
+	}
+	h.hints = append(h.hints, InlayHint{
+		Position:    protocol.Position{Line: uint32(line), Character: uint32(char)},
+		Label:       label,
+		Kind:        kind,
+		PaddingLeft: paddingLeft,
+	})
+}
+
+// Follow the `$ref`s of a schema, return the schema and the name of the definition it refers to. Schemas
+// with their references inlined have the name in `x-yamlls-ref`.
+func (h *inlayHinter) resolve(schema gjson.Result) (gjson.Result, string) {
+	name := schema.Get("x-yamlls-ref").String()
+	// io.k8s.api.core.v1.PodSpec is PodSpec
+	name = name[strings.LastIndexAny(name, "/.")+1:]
+	for range MAX_REF_DEPTH {
+		ref := schema.Get(`\$ref`).String()
+		if !strings.HasPrefix(ref, "#/") {
+			break
+		}
+		var segments []string
+		for _, segment := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			segments = append(segments, gjson.Escape(segment))
+		}
+		schema = h.root.Get(strings.Join(segments, "."))
+		name = ref[strings.LastIndexAny(ref, "/.")+1:]
+	}
+	return schema, name
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestInlayHints(t *testing.T) {
+	useTestSchemas(t, map[string][]byte{"Thing_example.com_v1.json": []byte(`{
+  "type": "object",
+  "properties": {
+    "apiVersion": {"type": "string"},
+    "kind": {"type": "string"},
+    "spec": {"$ref": "#/definitions/com.example.v1.ThingSpec"}
+  },
+  "definitions": {
+    "com.example.v1.ThingSpec": {
+      "type": "object",
+      "properties": {
+        "replicas": {"type": "integer", "default": 1},
+        "paused": {"type": "boolean", "default": false},
+        "ports": {"type": "array", "items": {"$ref": "#/definitions/com.example.v1.Port"}}
+      }
+    },
+    "com.example.v1.Port": {
+      "type": "object",
+      "properties": {
+        "port": {"type": "integer"},
+        "protocol": {"type": "string", "default": "TCP"}
+      }
+    }
+  }
+}`)})
+
+	file := `apiVersion: example.com/v1
+kind: Thing
+spec:
+  paused: true
+  ports:
+  - port: 80
+`
+	tests := map[string]struct {
+		start, end int
+		expected   []string
+	}{
+		"all": {
+			start: 0,
+			end:   5,
+			expected: []string{
+				"0:26 : string",
+				"1:11 : string",
+				"2:5 ThingSpec",
+				"2:5 defaults: {replicas: 1}",
+				"3:14 : boolean",
+				"4:8 []Port",
+				"5:12 : integer",
+				"5:12 defaults: {protocol: TCP}",
+			},
+		},
+		"range": {
+			start:    5,
+			end:      5,
+			expected: []string{"5:12 : integer", "5:12 defaults: {protocol: TCP}"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var actual []string
+			for _, hint := range inlayHints(file, test.start, test.end) {
+				actual = append(actual, fmt.Sprintf("%d:%d %s", hint.Position.Line, hint.Position.Character, hint.Label))
+			}
+			if !slices.Equal(actual, test.expected) {
+				t.Fatalf("expected\n%v\ngot\n%v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestInlayHintsResolvedRefs(t *testing.T) {
+	schemas, err := openApiSchemas([]byte(`{
+  "swagger": "2.0",
+  "definitions": {
+    "com.example.v1.Widget": {
+      "type": "object",
+      "x-kubernetes-group-version-kind": [{"group": "example.com", "version": "v1", "kind": "Widget"}],
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "spec": {"$ref": "#/definitions/com.example.v1.WidgetSpec"}
+      }
+    },
+    "com.example.v1.WidgetSpec": {
+      "type": "object",
+      "properties": {
+        "ports": {"type": "array", "items": {"$ref": "#/definitions/com.example.v1.Port"}}
+      }
+    },
+    "com.example.v1.Port": {
+      "type": "object",
+      "properties": {
+        "port": {"type": "integer"}
+      }
+    }
+  }
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	schema, found := schemas["Widget_example.com_v1.json"]
+	if !found {
+		t.Fatalf("expected a schema for Widget, got %v", slices.Collect(maps.Keys(schemas)))
+	}
+	if strings.Contains(string(schema), "$ref") {
+		t.Fatalf("expected the references to be inlined, got %s", schema)
+	}
+	schemaCache["Widget_example.com_v1.json"] = schema
+	t.Cleanup(func() { delete(schemaCache, "Widget_example.com_v1.json") })
+
+	file := `apiVersion: example.com/v1
+kind: Widget
+spec:
+  ports:
+  - port: 80
+`
+	var actual []string
+	for _, hint := range inlayHints(file, 0, 4) {
+		actual = append(actual, fmt.Sprintf("%d:%d %s", hint.Position.Line, hint.Position.Character, hint.Label))
+	}
+	expected := []string{
+		"0:26 : string",
+		"1:12 : string",
+		"2:5 WidgetSpec",
+		"3:8 []Port",
+		"4:12 : integer",
+	}
+	if !slices.Equal(actual, expected) {
+		t.Fatalf("expected\n%v\ngot\n%v", expected, actual)
+	}
+}
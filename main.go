@@ -10,14 +10,14 @@ import (
 	"log/slog"
 	"net/http"
 	"net/textproto"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"regexp"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/goccy/go-yaml"
 	"github.com/tidwall/gjson"
@@ -27,21 +27,28 @@ import (
 )
 
 var (
-	CACHE_DIR string
-	DB_DIR    string
-	logger    *slog.Logger
+	CACHE_DIR   string
+	CONFIG_DIR  string
+	DB_DIR      string
+	K8S_VERSION = DEFAULT_K8S_VERSION
+	logger      *slog.Logger
 )
 
+// The schemas for each kubernetes version are stored in DB_DIR/<version>
+const DEFAULT_K8S_VERSION = "master"
+
+// The defaults can be overridden with --cache-dir and --db-dir. The directories are created when
+// something is written to them.
 func init() {
-	userCacheDir, err := os.UserCacheDir()
-	if err != nil {
-		fatal("locate user's cache directory: %s", err)
+	if userCacheDir, err := os.UserCacheDir(); err == nil {
+		CACHE_DIR = filepath.Join(userCacheDir, "yamlls")
+	} else {
+		CACHE_DIR = filepath.Join(os.TempDir(), "yamlls")
 	}
-	CACHE_DIR = filepath.Join(userCacheDir, "yamlls")
-	DB_DIR = filepath.Join(CACHE_DIR, "db")
-	if err := os.MkdirAll(DB_DIR, 0755); err != nil {
-		fatal("create db dir for storing schemas %s: %s", DB_DIR, err)
+	if userConfigDir, err := os.UserConfigDir(); err == nil {
+		CONFIG_DIR = filepath.Join(userConfigDir, "yamlls")
 	}
+	DB_DIR = filepath.Join(CACHE_DIR, "db")
 }
 
 func fatal(format string, args ...any) {
@@ -50,265 +57,214 @@ func fatal(format string, args ...any) {
 }
 
 func main() {
-	if err := run(); err != nil {
+	if err := run(os.Args[1:]); err != nil {
+		var exitCode ExitCodeError
+		if errors.As(err, &exitCode) {
+			os.Exit(exitCode.Code)
+		}
 		fatal(err.Error())
 	}
 }
 
-func run() error {
-	if len(os.Args) < 2 {
-		if err := runLanguageServer(); err != nil {
-			return fmt.Errorf("run language server: %s", err)
-		}
-	} else {
-		subCommand, args := os.Args[1], os.Args[2:]
-		switch subCommand {
-		case "schemas":
-			if err := listSchemas(); err != nil {
-				return fmt.Errorf("list schemas: %s", err)
-			}
-		case "fill":
-			var basename string
-			path := "."
-			switch len(args) {
-			case 0:
-				return fmt.Errorf("must provide `basename`, e.g. `yamlls fill Deployment-apps-v1.json`. Get the basename from `yamlls schemas`.")
-			case 1:
-				basename = args[0]
-			case 2:
-				basename = args[0]
-				path = args[1]
-			}
-			schema, err := readSchema(basename)
-			if err != nil {
-				return fmt.Errorf("fill schema: read schema %s: %s", basename, err)
-			}
-			yamlDoc, err := schemaFill(schema, path)
-			if err != nil {
-				return fmt.Errorf("fill schema: %s", err)
-			}
-			fmt.Print(yamlDoc)
-		case "validate":
-			if len(args) == 0 {
-				return fmt.Errorf("must provide the filename to validate")
-			}
-			file := args[0]
-			bytes, err := os.ReadFile(file)
-			if err != nil {
-				return fmt.Errorf("read `%s`: %s", file, err)
-			}
-			errors, valFailure := fileValidate(string(bytes))
-			if valFailure != VALIDATION_FAILURE_REASON_NOT_A_FAILURE {
-				return fmt.Errorf("validate file %s: %s", file, valFailure)
-			}
-			for _, e := range errors {
-				fmt.Printf("%s:%d:%s\n", file, e.Range.Start.Line+1, e.Message)
-			}
-		case "refresh":
-			if err := refreshDatabase(); err != nil {
-				return fmt.Errorf("refresh database: %s", err)
-			}
-		default:
-			panic("TODO: Handle unknown subcommand")
-		}
+// Use the schemas for `version`, e.g. `1.29.0`, `v1.29.0` or `master`
+func setKubernetesVersion(version string) {
+	version = strings.TrimPrefix(version, "v")
+	if version == "" {
+		version = DEFAULT_K8S_VERSION
+	}
+	if version != K8S_VERSION {
+		K8S_VERSION = version
+		schemaCacheMu.Lock()
+		schemaCache = map[string][]byte{}
+		schemaCacheMu.Unlock()
 	}
-	return nil
 }
 
-const (
-	NATIVE_SCHEMAS_BASE_URL = "https://raw.githubusercontent.com/yannh/kubernetes-json-schema/refs/heads/master/master-standalone-strict"
-	CUSTOM_SCHEMAS_BASE_URL = "https://raw.githubusercontent.com/datreeio/CRDs-catalog/refs/heads/main"
-)
+func schemaDir() string {
+	return filepath.Join(DB_DIR, K8S_VERSION)
+}
 
-func refreshDatabase() error {
-	if err := os.RemoveAll(DB_DIR); err != nil {
-		return fmt.Errorf("remove `%s`: %s", DB_DIR, err)
+// Return the kubernetes versions that have schemas in the db
+func kubernetesVersions() ([]string, error) {
+	files, err := os.ReadDir(DB_DIR)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
 	}
-	if err := os.MkdirAll(DB_DIR, 0755); err != nil {
-		return fmt.Errorf("create `%s`: %s", DB_DIR, err)
+	if err != nil {
+		return nil, fmt.Errorf("read db %s: %s", DB_DIR, err)
 	}
-
-	type Definition struct{ url, basename string }
-
-	var definitionsToDownload []Definition
-	{
-		nativeDefinitionsUrl := fmt.Sprintf("%s/_definitions.json", NATIVE_SCHEMAS_BASE_URL)
-		body, err := httpGet(nativeDefinitionsUrl)
-		if err != nil {
-			return fmt.Errorf("get native definitions: %s", err)
-		}
-		var definitions struct {
-			Definitions map[string]struct {
-				GroupVersionKind []struct {
-					Group   string `json:"group"`
-					Kind    string `json:"kind"`
-					Version string `json:"version"`
-				} `json:"x-kubernetes-group-version-kind"`
-			} `json:"definitions"`
-		}
-		if err := json.Unmarshal(body, &definitions); err != nil {
-			return fmt.Errorf("unmarshal native definitions: %s", err)
-		}
-		for id, definition := range definitions.Definitions {
-			if strings.Contains(id, "apimachinery") || strings.Contains(id, "apiextensions") || strings.Contains(id, "apiserverinternal") || len(definition.GroupVersionKind) != 1 {
-				continue
-			}
-			gvk := definition.GroupVersionKind[0]
-			group := gvk.Group
-			groupFirstPart := strings.Split(gvk.Group, ".")[0]
-			schemaId := gvkToSchemaId(group, gvk.Version, gvk.Kind)
-			// NOTE: We want the group in schema id to be the full group, e.g. `networking.k8s.io`
-			//       But the group in the filename in the git repo is just `networking`
-			baseName := strings.Replace(schemaId, group, groupFirstPart, 1) + ".json"
-			baseName = strings.ReplaceAll(baseName, "_", "-")
-			schemaUrl := fmt.Sprintf("%s/%s", NATIVE_SCHEMAS_BASE_URL, strings.ToLower(baseName))
-			definitionsToDownload = append(definitionsToDownload, Definition{url: schemaUrl, basename: schemaId + ".json"})
+	var versions []string
+	for _, f := range files {
+		if f.IsDir() && !strings.HasPrefix(f.Name(), ".") {
+			versions = append(versions, f.Name())
 		}
 	}
+	return versions, nil
+}
 
-	{
-		customDefinitionsUrl := fmt.Sprintf("%s/index.yaml", CUSTOM_SCHEMAS_BASE_URL)
-		body, err := httpGet(customDefinitionsUrl)
+// The basenames of the schemas of each kubernetes version in the db. Reading them for every document
+// without a schema is slow, so they are read again only when the versions in DB_DIR change, which a
+// refresh does when it swaps in the new schemas.
+var (
+	dbVersionsMu      sync.Mutex
+	dbVersions        map[string]map[string]bool
+	dbVersionsDir     string
+	dbVersionsModTime time.Time
+)
+
+// Return the other kubernetes versions in the db that have a schema for `basename`
+func otherVersionsWithSchema(basename string) []string {
+	info, err := os.Stat(DB_DIR)
+	if err != nil {
+		return nil
+	}
+	dbVersionsMu.Lock()
+	defer dbVersionsMu.Unlock()
+	if dbVersions == nil || dbVersionsDir != DB_DIR || !dbVersionsModTime.Equal(info.ModTime()) {
+		versions, err := kubernetesVersions()
 		if err != nil {
-			return fmt.Errorf("get index for custom definitions: %s", err)
-		}
-		var index map[string][]struct {
-			ApiVersion string `yaml:"apiVersion"`
-			Kind       string `yaml:"kind"`
-			Filename   string `yaml:"filename"`
+			return nil
 		}
-		if err := yaml.Unmarshal(body, &index); err != nil {
-			return fmt.Errorf("unmarshal custom definitions index: %s", err)
-		}
-		for _, definitions := range index {
-			for _, d := range definitions {
-				if strings.Contains(d.Kind, "/") {
-					fmt.Fprintf(os.Stderr, "kind `%s` contains a `/`, it shouldn't\n", d.Kind)
-					continue
-				}
-				schemaUrl := fmt.Sprintf("%s/%s", CUSTOM_SCHEMAS_BASE_URL, d.Filename)
-				split := strings.Split(d.ApiVersion, "/")
-				if len(split) != 2 {
-					fmt.Fprintf(os.Stderr, "expected apiVersion to have exactly one `/`, got %s", d.ApiVersion)
-					continue
-				}
-				group, version := split[0], split[1]
-				schemaId := gvkToSchemaId(group, version, d.Kind)
-				definitionsToDownload = append(definitionsToDownload, Definition{
-					url:      schemaUrl,
-					basename: schemaId + ".json",
-				})
+		dbVersions = map[string]map[string]bool{}
+		dbVersionsDir, dbVersionsModTime = DB_DIR, info.ModTime()
+		for _, v := range versions {
+			dbVersions[v] = map[string]bool{}
+			files, _ := os.ReadDir(filepath.Join(DB_DIR, v))
+			for _, f := range files {
+				dbVersions[v][f.Name()] = true
 			}
 		}
 	}
-
-	worker := func(jobs <-chan Definition, results chan<- error) {
-		var schemaCompact bytes.Buffer
-		for definition := range jobs {
-			schema, err := httpGet(definition.url)
-			if err != nil {
-				results <- fmt.Errorf("get schema: %s", err)
-			}
-			if err := json.Compact(&schemaCompact, schema); err != nil {
-				results <- fmt.Errorf("make schema more compact: %s", err)
-			}
-			filename := filepath.Join(DB_DIR, definition.basename)
-			if err := os.WriteFile(filename, schemaCompact.Bytes(), 0644); err != nil {
-				results <- fmt.Errorf("write schema to %s: %s", filename, err)
-			}
-			schemaCompact.Reset()
-			results <- nil
+	var result []string
+	for v, basenames := range dbVersions {
+		if v != K8S_VERSION && basenames[basename] {
+			result = append(result, v)
 		}
 	}
-	jobs := make(chan Definition, len(definitionsToDownload))
-	results := make(chan error, len(definitionsToDownload))
+	slices.Sort(result)
+	return result
+}
 
-	workersCount := 50
-	for range workersCount {
-		go worker(jobs, results)
+// Before the schemas were stored per kubernetes version they were all in DB_DIR, downloaded for the
+// default version. Move them to the directory of that version so that they are used again.
+func migrateFlatDb() error {
+	files, err := os.ReadDir(DB_DIR)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
 	}
-
-	for _, d := range definitionsToDownload {
-		jobs <- d
+	if err != nil {
+		return fmt.Errorf("read db %s: %s", DB_DIR, err)
 	}
-	close(jobs)
-
-	for i := range len(definitionsToDownload) {
-		err := <-results
+	dir := filepath.Join(DB_DIR, DEFAULT_K8S_VERSION)
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("create %s: %s, run `yamlls refresh` to download the schemas again", dir, err)
+		}
+		flat, target := filepath.Join(DB_DIR, f.Name()), filepath.Join(dir, f.Name())
+		// A schema that is already in the version directory is newer
+		if _, err := os.Stat(target); err == nil {
+			err = os.Remove(flat)
+		} else {
+			err = os.Rename(flat, target)
+		}
 		if err != nil {
-			fmt.Fprint(os.Stderr, err)
+			return fmt.Errorf("move %s to %s: %s, run `yamlls refresh` to download the schemas again", flat, dir, err)
 		}
-		fmt.Fprintf(os.Stderr, "\r%4d/%d", i+1, len(definitionsToDownload))
 	}
-	fmt.Fprintln(os.Stderr)
 	return nil
 }
 
-func httpGet(url string) ([]byte, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("get %s: %s", url, err)
-	}
-	defer func() error {
-		if err := resp.Body.Close(); err != nil {
-			return fmt.Errorf("close body: %s", err)
-		}
-		return nil
-	}()
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("get %s: %s", url, resp.Status)
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read body: %s", err)
-	}
-	return body, nil
-}
-
-func listSchemas() error {
+func listSchemas(filter SchemaFilter) error {
 	ids, err := schemaIds()
 	if err != nil {
 		return fmt.Errorf("get schema ids: %s", err)
 	}
-	for _, id := range ids {
+	for _, id := range filterSchemaIds(ids, filter) {
 		fmt.Println(id)
 	}
 	return nil
 }
 
+// Return the schema ids in the db, in the embedded bundle if it is for the version and from the workspace
 func schemaIds() ([]string, error) {
-	files, err := os.ReadDir(DB_DIR)
+	ids, err := dbSchemaIds()
 	if err != nil {
-		return nil, fmt.Errorf("read db %s: %s", DB_DIR, err)
+		return nil, err
+	}
+	var bundled []string
+	if isBundleFor(K8S_VERSION) {
+		bundled = bundledSchemaIds()
+	}
+	for _, id := range slices.Concat(bundled, workspace.schemaIds()) {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func dbSchemaIds() ([]string, error) {
+	dir := schemaDir()
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read db %s: %s", dir, err)
 	}
 	var ids []string
 	for _, f := range files {
+		if strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
 		if f.IsDir() {
-			return nil, fmt.Errorf("expected all files in %s to be files, got a dir: %s", DB_DIR, f.Name())
+			return nil, fmt.Errorf("expected all files in %s to be files, got a dir: %s", dir, f.Name())
 		}
 		ids = append(ids, f.Name())
 	}
 	return ids, nil
 }
 
-var schemaCache = map[string][]byte{}
+// Schemas read from the db, by basename. Files are validated in parallel by `yamlls validate`.
+var (
+	schemaCache   = map[string][]byte{}
+	schemaCacheMu sync.RWMutex
+)
 
 var ErrSchemaNotExist = errors.New("no schema found")
 
 func readSchema(basename string) ([]byte, error) {
-	if schema, found := schemaCache[basename]; found {
+	if schema, found := workspace.schema(basename); found {
+		return schema, nil
+	}
+	schemaCacheMu.RLock()
+	schema, found := schemaCache[basename]
+	schemaCacheMu.RUnlock()
+	if found {
 		return schema, nil
 	}
-	filepath := filepath.Join(DB_DIR, basename)
+	filepath := filepath.Join(schemaDir(), basename)
 	bytes, err := os.ReadFile(filepath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return nil, ErrSchemaNotExist
+			if !isBundleFor(K8S_VERSION) {
+				return nil, ErrSchemaNotExist
+			}
+			bytes, err = readBundledSchema(basename)
+			if err != nil {
+				return nil, err
+			}
 		} else {
 			return nil, fmt.Errorf("read %s: %s", filepath, err)
 		}
 	}
+	schemaCacheMu.Lock()
 	schemaCache[basename] = bytes
+	schemaCacheMu.Unlock()
 	return bytes, nil
 }
 
@@ -318,9 +274,11 @@ func schemaIdToGvk(id string) GVK {
 	id = strings.TrimSuffix(id, ".json")
 	split := strings.Split(id, "_")
 	gvk := GVK{kind: split[0]}
-	if len(split) == 2 {
+	switch len(split) {
+	case 1:
+	case 2:
 		gvk.version = split[1]
-	} else {
+	default:
 		gvk.group = split[1]
 		gvk.version = split[2]
 	}
@@ -336,62 +294,10 @@ func gvkToSchemaId(group, version, kind string) string {
 	return id
 }
 
-type Schema struct {
-	Type        Type              `json:"type"`
-	Description string            `json:"description"`
-	Properties  map[string]Schema `json:"properties"`
-	Items       *Schema           `json:"items"`
-	AnyOf       []Schema          `json:"anyOf"`
-	AllOf       []Schema          `json:"allOf"`
-	OneOf       []Schema          `json:"oneOf"`
-	Const       string            `json:"const"`
-	Enum        []any             `json:"enum"`
-	Ref         string            `json:"$ref"`
-	Required    []string          `json:"required"`
-}
-
-type Type struct {
-	One  string
-	Many []string
-}
-
-func (t *Type) UnmarshalJSON(b []byte) error {
-	var val any
-	if err := json.Unmarshal(b, &val); err != nil {
-		return err
-	}
-	switch concreteVal := val.(type) {
-	case string:
-		t.One = concreteVal
-	case []any:
-		for _, e := range concreteVal {
-			if s, ok := e.(string); ok {
-				t.Many = append(t.Many, s)
-			} else {
-				return fmt.Errorf("expected string, got %v", e)
-			}
-		}
-	default:
-		return fmt.Errorf("expected string or array of strings, got %v", concreteVal)
-	}
-	return nil
-}
-
 func panicf(format string, args ...any) {
 	panic(fmt.Sprintf(format, args...))
 }
 
-const DOC_COMMAND = "generate-schema-doc"
-
-func htmlDocs(schemaPath string) (string, error) {
-	cmd := exec.Command(DOC_COMMAND, schemaPath)
-	cmd.Dir = CACHE_DIR
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("generate html docs: %v", err)
-	}
-	return filepath.Join(CACHE_DIR, "schema_doc.html"), nil
-}
-
 type Severity int
 
 const (
@@ -404,6 +310,11 @@ type ValidationError struct {
 	Message string
 	Type    string
 	Severity
+	// The schema the document was validated against, empty for errors that don't depend on a schema
+	SchemaId string
+	// The path of the field in the document that the error is about, e.g. `.spec.replicas`, for errors
+	// from the schema. For missing required properties, it is the path the property should have.
+	Path string
 }
 
 type ValidationFailureReason string
@@ -417,7 +328,20 @@ const (
 func fileValidate(file string) ([]ValidationError, ValidationFailureReason) {
 	documents := documentsInFile(file)
 	var validationErrors []ValidationError
+	resources := map[string]Resource{}
 	for _, doc := range documents {
+		if duplicates := duplicateKeys(doc.document); len(duplicates) > 0 {
+			// The document can't be decoded, validate it once the duplicates are removed
+			for _, d := range duplicates {
+				validationErrors = append(validationErrors, ValidationError{
+					Range:    newRange(doc.start+d.Range.Start.Line, d.Range.Start.Char, doc.start+d.Range.End.Line, d.Range.End.Char),
+					Message:  fmt.Sprintf("duplicate key `%s`, it is first defined on line %d", d.Key, doc.start+d.FirstLine+1),
+					Type:     "duplicate_key",
+					Severity: SEVERITY_ERROR,
+				})
+			}
+			continue
+		}
 		gvk, ok := documentGVK(doc.document)
 		if !ok {
 			validationErrors = append(validationErrors, ValidationError{
@@ -433,25 +357,62 @@ func fileValidate(file string) ([]ValidationError, ValidationFailureReason) {
 			continue
 		}
 
+		if resource, ok := documentResource(doc); ok {
+			if first, found := resources[resource.identity()]; found {
+				validationErrors = append(validationErrors, ValidationError{
+					Range:    resource.Range,
+					Message:  fmt.Sprintf("duplicate resource %s, it is also defined on line %d", resource, first.Range.Start.Line+1),
+					Type:     "duplicate_resource",
+					Severity: SEVERITY_WARN,
+				})
+			} else {
+				resources[resource.identity()] = resource
+			}
+		}
+
+		deprecation, deprecated := apiDeprecation(gvk, K8S_VERSION)
+		if deprecated {
+			range_, found := apiVersionValueRange(doc.document)
+			if !found {
+				range_ = Range{}
+			}
+			validationErrors = append(validationErrors, ValidationError{
+				Range:    newRange(doc.start+range_.Start.Line, range_.Start.Char, doc.start+range_.End.Line, range_.End.Char),
+				Message:  deprecation.message(K8S_VERSION),
+				Type:     "deprecated_api_version",
+				Severity: SEVERITY_WARN,
+			})
+		}
+
 		schemaId := gvkToSchemaId(gvk.group, gvk.version, gvk.kind)
 		schemaBytes, err := readSchema(schemaId + ".json")
 		if err != nil {
 			if errors.Is(err, ErrSchemaNotExist) {
-				apiVersion := fmt.Sprintf("%s/%s", gvk.group, gvk.version)
-				if gvk.group == "" {
-					apiVersion = gvk.version
+				if deprecated {
+					// Already reported as deprecated
+					continue
+				}
+				apiVersion := gvkApiVersion(gvk)
+				message := fmt.Sprintf("no schema found for %s %s in kubernetes %s", gvk.kind, apiVersion, K8S_VERSION)
+				if versions := otherVersionsWithSchema(schemaId + ".json"); len(versions) > 0 {
+					message += fmt.Sprintf(", it exists in %s", strings.Join(versions, ", "))
 				}
 				validationErrors = append(validationErrors, ValidationError{
 					Range:    newRange(doc.start, 0, doc.start, 0),
-					Message:  fmt.Sprintf("no schema found for %s %s", gvk.kind, apiVersion),
+					Message:  message,
 					Type:     "no_schema_found",
 					Severity: SEVERITY_WARN,
+					SchemaId: schemaId,
 				})
 				continue
 			} else {
 				return nil, VALIDATION_FAILURE_REASON_READ_SCHEMA
 			}
 		}
+		schemaBytes, parsedSchema, err := prepareSchema(schemaId, schemaBytes)
+		if err != nil {
+			return nil, VALIDATION_FAILURE_REASON_SCHEMA_INVALID
+		}
 		schemaLoader := gojsonschema.NewBytesLoader(schemaBytes)
 
 		jsonDocument, err := yaml.YAMLToJSON([]byte(doc.document))
@@ -474,38 +435,65 @@ func fileValidate(file string) ([]ValidationError, ValidationFailureReason) {
 				field = "." + e.Field()
 			}
 			if e.Type() == "additional_property_not_allowed" {
-				if field != "." {
-					field += "."
-				}
-				field += e.Details()["property"].(string)
+				field = joinPath(field, e.Details()["property"].(string))
+			}
+			path := field
+			if e.Type() == "required" {
+				path = joinPath(field, e.Details()["property"].(string))
 			}
 			range_, found := paths[field]
 			if !found {
 				panicf("expected path `%s` to exist in the document. Available paths: %v. Error type: %s", field, paths, e.Type())
 			}
 			validationErrors = append(validationErrors, ValidationError{
-				Range:   newRange(doc.start+range_.Start.Line, range_.Start.Char, doc.start+range_.End.Line, range_.End.Char),
-				Message: e.Description(),
-				Type:    e.Type(), // I've got life!
+				Range:    newRange(doc.start+range_.Start.Line, range_.Start.Char, doc.start+range_.End.Line, range_.End.Char),
+				Message:  e.Description(),
+				Type:     e.Type(), // I've got life!
+				SchemaId: schemaId,
+				Path:     path,
 			})
 		}
+		if parsedSchema != nil {
+			// Keep the numbers as they are written, CEL has both ints and doubles
+			decoder := json.NewDecoder(bytes.NewReader(jsonDocument))
+			decoder.UseNumber()
+			var value any
+			if err := decoder.Decode(&value); err != nil {
+				panicf("yaml.YAMLToJSON returned invalid json: %s", err)
+			}
+			for _, e := range extensionErrors(parsedSchema, value, ".") {
+				range_ := closestPathRange(paths, e.Path)
+				validationErrors = append(validationErrors, ValidationError{
+					Range:    newRange(doc.start+range_.Start.Line, range_.Start.Char, doc.start+range_.End.Line, range_.End.Char),
+					Message:  e.Message,
+					Type:     e.Type,
+					Severity: e.Severity,
+					SchemaId: schemaId,
+					Path:     e.Path,
+				})
+			}
+		}
 	}
 	return validationErrors, VALIDATION_FAILURE_REASON_NOT_A_FAILURE
 }
 
 var exitChannel chan (int)
 var documentUpdates chan (protocol.TextDocumentItem)
+var workspaceIndexed chan (struct{})
 var filenameToContents map[string]string
 var m *Mux
 
 func runLanguageServer() error {
-	logpath := filepath.Join(CACHE_DIR, "log.json")
-	logfile, err := os.Create(logpath)
-	if err != nil {
-		return fmt.Errorf("create log file: %s", err)
+	// Log to stderr if the cache dir isn't writable, stdout is used for the protocol
+	var logOutput io.Writer = os.Stderr
+	if err := os.MkdirAll(CACHE_DIR, 0755); err == nil {
+		logfile, err := os.Create(filepath.Join(CACHE_DIR, "log.json"))
+		if err == nil {
+			defer logfile.Close()
+			logOutput = logfile
+		}
 	}
-	defer logfile.Close()
-	logger = slog.New(slog.NewJSONHandler(logfile, nil))
+	logger = slog.New(slog.NewJSONHandler(logOutput, nil))
 	defer func() {
 		if r := recover(); r != nil {
 			logger.Error("panic", "recovered", r)
@@ -516,6 +504,7 @@ func runLanguageServer() error {
 
 	exitChannel = make(chan int, 1)
 	documentUpdates = make(chan protocol.TextDocumentItem, 10)
+	workspaceIndexed = make(chan struct{}, 1)
 	filenameToContents = map[string]string{}
 
 	m.HandleMethod(protocol.MethodInitialize, lspInitialize)
@@ -527,52 +516,40 @@ func runLanguageServer() error {
 	m.HandleMethod(protocol.MethodTextDocumentHover, lspTextDocumentHover)
 	m.HandleMethod(protocol.MethodTextDocumentCompletion, lspTextDocumentCompletion)
 	m.HandleMethod(protocol.MethodTextDocumentCodeAction, lspMethodTextDocumentCodeAction)
+	m.HandleMethod(protocol.MethodTextDocumentFormatting, lspTextDocumentFormatting)
+	m.HandleMethod(protocol.MethodTextDocumentRangeFormatting, lspTextDocumentRangeFormatting)
+	m.HandleMethod(protocol.MethodSemanticTokensFull, lspTextDocumentSemanticTokensFull)
+	m.HandleMethod(METHOD_TEXT_DOCUMENT_INLAY_HINT, lspTextDocumentInlayHint)
+	m.HandleMethod(protocol.MethodTextDocumentSignatureHelp, lspTextDocumentSignatureHelp)
+	m.HandleMethod(protocol.MethodTextDocumentFoldingRange, lspTextDocumentFoldingRange)
+	m.HandleMethod(METHOD_TEXT_DOCUMENT_SELECTION_RANGE, lspTextDocumentSelectionRange)
 	m.HandleMethod(protocol.MethodWorkspaceExecuteCommand, lspMethodWorkspaceExecuteCommand)
 
 	go func() {
-		for doc := range documentUpdates {
-			filenameToContents[doc.URI.Filename()] = doc.Text
-			errors, err := fileValidate(doc.Text)
-			if err != VALIDATION_FAILURE_REASON_NOT_A_FAILURE {
-				logger.Error("validate file", "err", fmt.Sprintf("%#v", err))
-			}
-			diagnostics := []protocol.Diagnostic{}
-			for _, e := range errors {
-				var severity protocol.DiagnosticSeverity
-				switch e.Severity {
-				case SEVERITY_ERROR:
-					severity = protocol.DiagnosticSeverityError
-				case SEVERITY_WARN:
-					severity = protocol.DiagnosticSeverityWarning
-				default:
-					// Should not happen
-					severity = protocol.DiagnosticSeverityInformation
+		openDocuments := map[string]protocol.TextDocumentItem{}
+		for {
+			select {
+			case doc := <-documentUpdates:
+				filename := doc.URI.Filename()
+				openDocuments[filename] = doc
+				if workspace.indexFile(filename, doc.Text) {
+					// The schemas for custom resources or the resources in the workspace changed, validate all open
+					// documents again
+					for _, doc := range openDocuments {
+						publishDiagnostics(doc)
+					}
+				} else {
+					publishDiagnostics(doc)
+				}
+			case <-workspaceIndexed:
+				for _, doc := range openDocuments {
+					publishDiagnostics(doc)
 				}
-				diagnostics = append(diagnostics, protocol.Diagnostic{
-					Range: protocol.Range{
-						Start: protocol.Position{
-							Line:      uint32(e.Range.Start.Line),
-							Character: uint32(e.Range.Start.Char),
-						},
-						End: protocol.Position{
-							Line:      uint32(e.Range.End.Line),
-							Character: uint32(e.Range.End.Char),
-						},
-					},
-					Severity: severity,
-					Source:   "yamlls",
-					Message:  e.Message,
-				})
 			}
-			m.Notify(protocol.MethodTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
-				URI:         doc.URI,
-				Version:     uint32(doc.Version),
-				Diagnostics: diagnostics,
-			})
 		}
 	}()
 
-	logger.Info("Handler set up", "log_path", logpath)
+	logger.Info("Handler set up", "cache_dir", CACHE_DIR)
 
 	go func() {
 		if err := m.Process(); err != nil {
@@ -588,6 +565,77 @@ func runLanguageServer() error {
 	return nil
 }
 
+// Config is given as `initializationOptions` by the client
+type Config struct {
+	// The kubernetes version to validate against, e.g. `1.29.0`. Defaults to `master`.
+	KubernetesVersion string `json:"kubernetesVersion"`
+	// Serve the documentation on 127.0.0.1 and open it from there instead of from static files
+	DocsServer bool `json:"docsServer"`
+	// The port of the documentation server, a random port if it is 0
+	DocsPort int `json:"docsPort"`
+	// The levels of properties to fill with the fill code action, defaults to 1
+	FillDepth int `json:"fillDepth"`
+	// Fill with defaults, examples and enum values instead of zero values
+	FillDefaults bool `json:"fillDefaults"`
+	// Sort keys when formatting, `apiVersion`, `kind`, `metadata`, `spec` and `data` first
+	FormatSortKeys bool `json:"formatSortKeys"`
+	// The number of spaces to indent with when formatting, taken from the file if 0
+	FormatIndent int `json:"formatIndent"`
+}
+
+func publishDiagnostics(doc protocol.TextDocumentItem) {
+	filename := doc.URI.Filename()
+	var errors []ValidationError
+	var err ValidationFailureReason
+	if isHelmTemplate(filename) {
+		// All requests work on the masked file, the positions are the same
+		filenameToContents[filename], _ = maskTemplateActions(doc.Text)
+		errors, err = fileValidateTemplate(doc.Text)
+	} else {
+		filenameToContents[filename] = doc.Text
+		errors, err = fileValidate(doc.Text)
+		errors = append(errors, workspace.duplicateResources(filename)...)
+	}
+	if err != VALIDATION_FAILURE_REASON_NOT_A_FAILURE {
+		logger.Error("validate file", "err", fmt.Sprintf("%#v", err))
+	}
+	diagnostics := []protocol.Diagnostic{}
+	for _, e := range errors {
+		var severity protocol.DiagnosticSeverity
+		switch e.Severity {
+		case SEVERITY_ERROR:
+			severity = protocol.DiagnosticSeverityError
+		case SEVERITY_WARN:
+			severity = protocol.DiagnosticSeverityWarning
+		default:
+			// Should not happen
+			severity = protocol.DiagnosticSeverityInformation
+		}
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range: protocol.Range{
+				Start: protocol.Position{
+					Line:      uint32(e.Range.Start.Line),
+					Character: uint32(e.Range.Start.Char),
+				},
+				End: protocol.Position{
+					Line:      uint32(e.Range.End.Line),
+					Character: uint32(e.Range.End.Char),
+				},
+			},
+			Severity: severity,
+			Code:     e.Type,
+			Source:   "yamlls",
+			Message:  e.Message,
+			Data:     DiagnosticData{SchemaId: e.SchemaId, Path: e.Path},
+		})
+	}
+	m.Notify(protocol.MethodTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
+		URI:         doc.URI,
+		Version:     uint32(doc.Version),
+		Diagnostics: diagnostics,
+	})
+}
+
 func lspInitialize(params json.RawMessage) (any, error) {
 	var initializeParams protocol.InitializeParams
 	if err := json.Unmarshal(params, &initializeParams); err != nil {
@@ -595,25 +643,87 @@ func lspInitialize(params json.RawMessage) (any, error) {
 	}
 	logger.Info("Received initialize request", "params", initializeParams)
 	// TODO: Support filenameOverrides
+	var config Config
+	if initializeParams.InitializationOptions != nil {
+		b, err := json.Marshal(initializeParams.InitializationOptions)
+		if err != nil {
+			return nil, fmt.Errorf("marshal initialization options: %s", err)
+		}
+		if err := json.Unmarshal(b, &config); err != nil {
+			return nil, fmt.Errorf("invalid initialization options: %s", err)
+		}
+	}
+	setKubernetesVersion(config.KubernetesVersion)
+	logger.Info("Using schemas", "kubernetes_version", K8S_VERSION)
+	FILL_OPTIONS = FillOptions{depth: config.FillDepth, defaults: config.FillDefaults}
+	FORMAT_OPTIONS = FormatOptions{indent: config.FormatIndent, sortKeys: config.FormatSortKeys}
+	if config.DocsServer {
+		listener, docsUrl, err := listenDocs(config.DocsPort)
+		if err != nil {
+			return nil, fmt.Errorf("start docs server: %s", err)
+		}
+		DOCS_SERVER_URL = docsUrl
+		go func() {
+			if err := http.Serve(listener, docsHandler()); err != nil {
+				logger.Error("Docs server stopped", "error", err)
+			}
+		}()
+		logger.Info("Serving docs", "url", DOCS_SERVER_URL)
+	}
 
-	result := protocol.InitializeResult{
-		Capabilities: protocol.ServerCapabilities{
-			TextDocumentSync:   protocol.TextDocumentSyncKindFull,
-			HoverProvider:      true,
-			CodeActionProvider: true,
-			ExecuteCommandProvider: &protocol.ExecuteCommandOptions{
-				Commands: []string{"open-docs", "fill"},
-			},
-			CompletionProvider: &protocol.CompletionOptions{
-				// ResolveProvider:   false,
-				// TriggerCharacters: []string{},
-			},
+	var roots []string
+	for _, folder := range initializeParams.WorkspaceFolders {
+		roots = append(roots, uri.URI(folder.URI).Filename())
+	}
+	if len(roots) == 0 && initializeParams.RootURI != "" {
+		roots = append(roots, initializeParams.RootURI.Filename())
+	}
+	go func() {
+		for _, root := range roots {
+			workspace.indexDir(root)
+		}
+		logger.Info("Indexed workspace", "roots", roots, "custom_resource_schemas", len(workspace.schemaIds()))
+		workspaceIndexed <- struct{}{}
+	}()
+
+	capabilities := protocol.ServerCapabilities{
+		TextDocumentSync:                protocol.TextDocumentSyncKindFull,
+		HoverProvider:                   true,
+		CodeActionProvider:              true,
+		DocumentFormattingProvider:      true,
+		DocumentRangeFormattingProvider: true,
+		SemanticTokensProvider:          SemanticTokensOptions{Legend: semanticTokensLegend, Full: true},
+		FoldingRangeProvider:            true,
+		SelectionRangeProvider:          true,
+		ExecuteCommandProvider: &protocol.ExecuteCommandOptions{
+			Commands: []string{"open-docs", "fill"},
+		},
+		CompletionProvider: &protocol.CompletionOptions{
+			// ResolveProvider:   false,
+			// TriggerCharacters: []string{},
+		},
+		SignatureHelpProvider: &protocol.SignatureHelpOptions{
+			TriggerCharacters: []string{":", " "},
 		},
-		ServerInfo: &protocol.ServerInfo{Name: "yamlls"},
+	}
+	result := InitializeResult{
+		Capabilities: ServerCapabilities{ServerCapabilities: capabilities, InlayHintProvider: true},
+		ServerInfo:   &protocol.ServerInfo{Name: "yamlls"},
 	}
 	return result, nil
 }
 
+// The protocol package lacks the capabilities added in LSP 3.17
+type ServerCapabilities struct {
+	protocol.ServerCapabilities
+	InlayHintProvider bool `json:"inlayHintProvider,omitempty"`
+}
+
+type InitializeResult struct {
+	Capabilities ServerCapabilities   `json:"capabilities"`
+	ServerInfo   *protocol.ServerInfo `json:"serverInfo,omitempty"`
+}
+
 func lspInitialized(params json.RawMessage) error {
 	logger.Info("Receivied initialized notification")
 	return nil
@@ -702,6 +812,26 @@ func lspTextDocumentCompletion(rawParams json.RawMessage) (any, error) {
 	file := filenameToContents[params.TextDocument.URI.Filename()]
 	lines := strings.Split(file, "\n")
 	currentLine := lines[params.Position.Line]
+	if !strings.Contains(currentLine, ":") && isBlankDocumentAt(file, int(params.Position.Line)) {
+		// new, a manifest in an empty document
+		completionItems := []protocol.CompletionItem{}
+		for _, kind := range scaffoldKinds() {
+			completionItems = append(completionItems, protocol.CompletionItem{
+				Label:            kind,
+				Kind:             protocol.CompletionItemKindSnippet,
+				Detail:           "New " + kind,
+				InsertTextFormat: protocol.InsertTextFormatSnippet,
+				TextEdit: &protocol.TextEdit{
+					Range: protocol.Range{
+						Start: protocol.Position{Line: params.Position.Line, Character: 0},
+						End:   protocol.Position{Line: params.Position.Line, Character: uint32(len(currentLine))},
+					},
+					NewText: scaffolds[kind],
+				},
+			})
+		}
+		return completionItems, nil
+	}
 	if !strings.HasPrefix(currentLine, "ap") {
 		return nil, nil
 	}
@@ -760,82 +890,74 @@ func lspMethodTextDocumentCodeAction(rawParams json.RawMessage) (any, error) {
 		return codeActions, err
 	}
 	file := filenameToContents[params.TextDocument.URI.Filename()]
+	line := int(params.Range.Start.Line)
+
+	if lines := strings.Split(file, "\n"); line < len(lines) && !strings.Contains(lines[line], ":") && isBlankDocumentAt(file, line) {
+		// new, insert a manifest in an empty document
+		for _, kind := range scaffoldKinds() {
+			codeActions = append(codeActions, newManifestCodeAction(params.TextDocument.URI, kind, scaffolds[kind], protocol.Range{
+				Start: protocol.Position{Line: uint32(line), Character: 0},
+				End:   protocol.Position{Line: uint32(line), Character: uint32(len(lines[line]))},
+			}))
+		}
+		return codeActions, nil
+	}
 
-	currentDocument, lineInDocument, found := documentAtPosition(file, int(params.Range.Start.Line))
+	for _, diagnostic := range params.Context.Diagnostics {
+		codeActions = append(codeActions, quickFixCodeActions(params.TextDocument.URI, file, diagnostic)...)
+	}
+
+	currentDocument, lineInDocument, found := documentAtPosition(file, line)
 	if !found {
-		return nil, nil
+		return codeActions, nil
 	}
-	pathAtCursor, pathRangeAtCursor, found := pathAtPosition(currentDocument, lineInDocument, int(params.Range.Start.Character))
+	pathAtCursor, _, found := pathAtPosition(currentDocument, lineInDocument, int(params.Range.Start.Character))
 
 	gvk, ok := documentGVK(currentDocument)
 	if !ok {
 		return nil, errors.New("invalid yaml")
 	}
+	if gvk.kind != "" && gvk.version == "" && !strings.Contains(strings.TrimSpace(currentDocument), "\n") {
+		// new, replace a document with only a kind with a manifest for it
+		snippet, err := scaffoldSnippet(gvk.kind)
+		if err != nil {
+			return nil, fmt.Errorf("scaffold %s: %s", gvk.kind, err)
+		}
+		documentStart := uint32(line - lineInDocument)
+		codeActions = append(codeActions, newManifestCodeAction(params.TextDocument.URI, gvk.kind, snippet, protocol.Range{
+			Start: protocol.Position{Line: documentStart, Character: 0},
+			End:   protocol.Position{Line: documentStart + uint32(strings.Count(currentDocument, "\n")), Character: 0},
+		}))
+		return codeActions, nil
+	}
 	if gvk.kind == "" || gvk.version == "" {
 		return nil, errors.New("no kind or apiVersion found")
 	}
-	schemaId := gvkToSchemaId(gvk.group, gvk.version, gvk.kind)
-	schema, err := readSchema(schemaId + ".json")
-	if err != nil {
-		return codeActions, fmt.Errorf("no schema found for %s", schemaId)
-	}
-
-	{
-		// open-docs
-		schemaFilepath := filepath.Join(DB_DIR, schemaId+".json")
-		if _, err := exec.LookPath(DOC_COMMAND); err == nil {
-			codeActions = append(codeActions, protocol.CodeAction{
-				Title: "Open documentation",
-				Command: &protocol.Command{
-					Title:     "Open documentation",
-					Command:   "open-docs",
-					Arguments: []any{schemaFilepath},
-				},
-			})
-		}
-	}
 
-	{
-		// fill
-		newText, err := schemaFill(schema, pathAtCursor)
-		if err != nil {
-			logger.Error("fill schema", "err", err)
-		} else {
-			// TODO: `{}`, `""`, 0 should be one the same row as the property we are filling, not the next line
-			indentLevel := pathRangeAtCursor.Start.Char
-			endLine := params.Range.Start.Line + 1
-			higherLevelPattern := regexp.MustCompile(fmt.Sprintf(`^%s\s*[^ ]`, strings.Repeat(" ", indentLevel+1)))
-			for _, line := range strings.Split(currentDocument, "\n")[lineInDocument+1:] {
-				if !higherLevelPattern.MatchString(line) || line == "---" {
-					break
-				}
-				endLine += 1
-			}
-			var lines []string
-			for _, line := range strings.Split(newText, "\n") {
-				if line == "" {
-					continue
-				}
-				lines = append(lines, strings.Repeat(" ", indentLevel+2)+line)
-			}
-			newText = "\n" + strings.Join(lines, "\n") + "\n"
+	deprecation, deprecated := apiDeprecation(gvk, K8S_VERSION)
+	if replacement, served := deprecation.servedReplacement(K8S_VERSION); deprecated && served {
+		// update-api-version
+		range_, found := apiVersionValueRange(currentDocument)
+		if found && range_.Start.Line == lineInDocument {
+			documentStart := int(params.Range.Start.Line) - lineInDocument
 			codeActions = append(codeActions, protocol.CodeAction{
-				Title: "Fill " + pathAtCursor,
+				Title: "Change apiVersion to " + replacement,
+				Kind:  protocol.QuickFix,
 				Edit: &protocol.WorkspaceEdit{
 					Changes: map[protocol.DocumentURI][]protocol.TextEdit{
 						params.TextDocument.URI: {
 							{
 								Range: protocol.Range{
 									Start: protocol.Position{
-										Line:      params.Range.Start.Line,
-										Character: uint32(pathRangeAtCursor.End.Char) + 1,
+										Line:      uint32(documentStart + range_.Start.Line),
+										Character: uint32(range_.Start.Char),
 									},
 									End: protocol.Position{
-										Line:      endLine,
-										Character: 0,
+										Line:      uint32(documentStart + range_.End.Line),
+										Character: uint32(range_.End.Char),
 									},
 								},
-								NewText: newText,
+								NewText: replacement,
 							},
 						},
 					},
@@ -843,9 +965,259 @@ func lspMethodTextDocumentCodeAction(rawParams json.RawMessage) (any, error) {
 			})
 		}
 	}
+
+	schemaId := gvkToSchemaId(gvk.group, gvk.version, gvk.kind)
+	schema, err := readSchema(schemaId + ".json")
+	if err != nil {
+		logger.Info("no schema found", "schema_id", schemaId, "err", err)
+		return codeActions, nil
+	}
+
+	{
+		// open-docs, at the field under the cursor
+		codeActions = append(codeActions, protocol.CodeAction{
+			Title: "Open documentation",
+			Command: &protocol.Command{
+				Title:     "Open documentation",
+				Command:   "open-docs",
+				Arguments: []any{schemaId, docsAnchor(pathAtCursor)},
+			},
+		})
+	}
+
+	if found {
+		// fill, with all properties to the configured depth and with only the required ones
+		fills := []struct {
+			title   string
+			options FillOptions
+		}{
+			{"Fill " + pathAtCursor, FILL_OPTIONS},
+			{"Fill required fields of " + pathAtCursor, FillOptions{required: true, defaults: FILL_OPTIONS.defaults}},
+		}
+		for _, fill := range fills {
+			filled, err := fillSchema(schema, pathAtCursor, fill.options)
+			if err != nil {
+				logger.Error("fill schema", "err", err)
+				continue
+			}
+			edits, err := fillEdits(currentDocument, pathAtCursor, filled)
+			if err != nil {
+				logger.Error("fill document", "err", err)
+				continue
+			}
+			if len(edits) == 0 {
+				continue
+			}
+			documentStart := int(params.Range.Start.Line) - lineInDocument
+			var textEdits []protocol.TextEdit
+			for _, e := range edits {
+				textEdits = append(textEdits, protocol.TextEdit{
+					Range: protocol.Range{
+						Start: protocol.Position{
+							Line:      uint32(documentStart + e.Range.Start.Line),
+							Character: uint32(e.Range.Start.Char),
+						},
+						End: protocol.Position{
+							Line:      uint32(documentStart + e.Range.End.Line),
+							Character: uint32(e.Range.End.Char),
+						},
+					},
+					NewText: e.NewText,
+				})
+			}
+			codeActions = append(codeActions, protocol.CodeAction{
+				Title: fill.title,
+				Edit: &protocol.WorkspaceEdit{
+					Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+						params.TextDocument.URI: textEdits,
+					},
+				},
+			})
+		}
+	}
 	return codeActions, nil
 }
 
+// Return the quick fixes for a diagnostic from publishDiagnostics
+func quickFixCodeActions(documentUri protocol.DocumentURI, file string, diagnostic protocol.Diagnostic) []protocol.CodeAction {
+	if diagnostic.Source != "yamlls" || diagnostic.Data == nil {
+		return nil
+	}
+	var data DiagnosticData
+	b, err := json.Marshal(diagnostic.Data)
+	if err != nil {
+		return nil
+	}
+	if err := json.Unmarshal(b, &data); err != nil || data.SchemaId == "" || data.Path == "" {
+		return nil
+	}
+	errorType, _ := diagnostic.Code.(string)
+	doc, lineInDocument, found := documentAtPosition(file, int(diagnostic.Range.Start.Line))
+	if !found {
+		return nil
+	}
+	schema, err := readSchema(data.SchemaId + ".json")
+	if err != nil {
+		logger.Error("read schema for quick fix", "schema_id", data.SchemaId, "err", err)
+		return nil
+	}
+	documentStart := int(diagnostic.Range.Start.Line) - lineInDocument
+	var codeActions []protocol.CodeAction
+	for _, fix := range quickFixes(doc, schema, errorType, data.Path) {
+		var textEdits []protocol.TextEdit
+		for _, e := range fix.Edits {
+			textEdits = append(textEdits, protocol.TextEdit{
+				Range: protocol.Range{
+					Start: protocol.Position{
+						Line:      uint32(documentStart + e.Range.Start.Line),
+						Character: uint32(e.Range.Start.Char),
+					},
+					End: protocol.Position{
+						Line:      uint32(documentStart + e.Range.End.Line),
+						Character: uint32(e.Range.End.Char),
+					},
+				},
+				NewText: e.NewText,
+			})
+		}
+		codeActions = append(codeActions, protocol.CodeAction{
+			Title:       fix.Title,
+			Kind:        protocol.QuickFix,
+			Diagnostics: []protocol.Diagnostic{diagnostic},
+			IsPreferred: fix.Preferred,
+			Edit: &protocol.WorkspaceEdit{
+				Changes: map[protocol.DocumentURI][]protocol.TextEdit{documentUri: textEdits},
+			},
+		})
+	}
+	return codeActions
+}
+
+// Code actions can't have snippets, the placeholders are replaced with their default values
+func newManifestCodeAction(uri protocol.DocumentURI, kind, snippet string, range_ protocol.Range) protocol.CodeAction {
+	return protocol.CodeAction{
+		Title: "New " + kind,
+		Edit: &protocol.WorkspaceEdit{
+			Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+				uri: {{Range: range_, NewText: expandSnippet(snippet, "")}},
+			},
+		},
+	}
+}
+
+func lspTextDocumentFormatting(rawParams json.RawMessage) (any, error) {
+	logger.Info(fmt.Sprintf("Received %s request", protocol.MethodTextDocumentFormatting))
+	var params protocol.DocumentFormattingParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return nil, err
+	}
+	filename := params.TextDocument.URI.Filename()
+	if isHelmTemplate(filename) {
+		return nil, nil
+	}
+	file := filenameToContents[filename]
+	formatted, err := formatFile(file, FORMAT_OPTIONS)
+	if err != nil {
+		return nil, err
+	}
+	if formatted == file {
+		return []protocol.TextEdit{}, nil
+	}
+	lines := strings.Split(file, "\n")
+	return []protocol.TextEdit{{
+		Range: protocol.Range{
+			End: protocol.Position{Line: uint32(len(lines) - 1), Character: uint32(len(lines[len(lines)-1]))},
+		},
+		NewText: formatted,
+	}}, nil
+}
+
+// Format the documents that overlap the range
+func lspTextDocumentRangeFormatting(rawParams json.RawMessage) (any, error) {
+	logger.Info(fmt.Sprintf("Received %s request", protocol.MethodTextDocumentRangeFormatting))
+	var params protocol.DocumentRangeFormattingParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return nil, err
+	}
+	filename := params.TextDocument.URI.Filename()
+	if isHelmTemplate(filename) {
+		return nil, nil
+	}
+	edits, err := formatRangeEdits(filenameToContents[filename], int(params.Range.Start.Line), int(params.Range.End.Line), FORMAT_OPTIONS)
+	if err != nil {
+		return nil, err
+	}
+	textEdits := []protocol.TextEdit{}
+	for _, e := range edits {
+		textEdits = append(textEdits, protocol.TextEdit{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: uint32(e.Range.Start.Line), Character: uint32(e.Range.Start.Char)},
+				End:   protocol.Position{Line: uint32(e.Range.End.Line), Character: uint32(e.Range.End.Char)},
+			},
+			NewText: e.NewText,
+		})
+	}
+	return textEdits, nil
+}
+
+func lspTextDocumentSemanticTokensFull(rawParams json.RawMessage) (any, error) {
+	logger.Info(fmt.Sprintf("Received %s request", protocol.MethodSemanticTokensFull))
+	var params protocol.SemanticTokensParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return nil, err
+	}
+	file := filenameToContents[params.TextDocument.URI.Filename()]
+	return protocol.SemanticTokens{Data: semanticTokens(file)}, nil
+}
+
+func lspTextDocumentInlayHint(rawParams json.RawMessage) (any, error) {
+	logger.Info(fmt.Sprintf("Received %s request", METHOD_TEXT_DOCUMENT_INLAY_HINT))
+	var params InlayHintParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return nil, err
+	}
+	file := filenameToContents[params.TextDocument.URI.Filename()]
+	hints := inlayHints(file, int(params.Range.Start.Line), int(params.Range.End.Line))
+	if hints == nil {
+		return []InlayHint{}, nil
+	}
+	return hints, nil
+}
+
+func lspTextDocumentSignatureHelp(rawParams json.RawMessage) (any, error) {
+	logger.Info(fmt.Sprintf("Received %s request", protocol.MethodTextDocumentSignatureHelp))
+	var params protocol.SignatureHelpParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return nil, err
+	}
+	file := filenameToContents[params.TextDocument.URI.Filename()]
+	help, found := signatureHelp(file, int(params.Position.Line), int(params.Position.Character))
+	if !found {
+		return nil, nil
+	}
+	return help, nil
+}
+
+func lspTextDocumentFoldingRange(rawParams json.RawMessage) (any, error) {
+	logger.Info(fmt.Sprintf("Received %s request", protocol.MethodTextDocumentFoldingRange))
+	var params protocol.FoldingRangeParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return nil, err
+	}
+	file := filenameToContents[params.TextDocument.URI.Filename()]
+	return foldingRanges(file), nil
+}
+
+func lspTextDocumentSelectionRange(rawParams json.RawMessage) (any, error) {
+	logger.Info(fmt.Sprintf("Received %s request", METHOD_TEXT_DOCUMENT_SELECTION_RANGE))
+	var params protocol.SelectionRangeParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return nil, err
+	}
+	file := filenameToContents[params.TextDocument.URI.Filename()]
+	return selectionRanges(file, params.Positions), nil
+}
+
 func lspMethodWorkspaceExecuteCommand(rawParams json.RawMessage) (any, error) {
 	logger.Info(fmt.Sprintf("Received %s request", protocol.MethodWorkspaceExecuteCommand))
 	var params protocol.ExecuteCommandParams
@@ -855,16 +1227,31 @@ func lspMethodWorkspaceExecuteCommand(rawParams json.RawMessage) (any, error) {
 	logger.Info("Received command", "command", params.Command, "args", params.Arguments)
 	switch params.Command {
 	case "open-docs":
-		if len(params.Arguments) != 1 {
-			return "", fmt.Errorf("Must provide 1 argument to open-docs: the filepath to the schema")
+		if len(params.Arguments) == 0 {
+			return "", fmt.Errorf("Must provide the schema id, and optionally the anchor of a field, to open-docs")
 		}
-		schemaFilepath := params.Arguments[0].(string)
-		docsFilepath, err := htmlDocs(schemaFilepath)
-		if err != nil {
-			return nil, errors.New("failed to generate html docs")
+		schemaId, _ := params.Arguments[0].(string)
+		var docsUri string
+		if DOCS_SERVER_URL != "" {
+			basename, err := findSchemaId(schemaId)
+			if err != nil {
+				return nil, err
+			}
+			docsUri = DOCS_SERVER_URL + "/" + url.PathEscape(strings.TrimSuffix(basename, ".json"))
+		} else {
+			docsFilepath, err := schemaDocs(schemaId, DOCS_FORMAT_HTML)
+			if err != nil {
+				return nil, fmt.Errorf("generate html docs: %s", err)
+			}
+			docsUri = "file://" + docsFilepath
+		}
+		if len(params.Arguments) > 1 {
+			if anchor, _ := params.Arguments[1].(string); anchor != "" {
+				docsUri += "#" + anchor
+			}
 		}
 
-		uri := uri.URI("file://" + docsFilepath)
+		uri := uri.URI(docsUri)
 		showDocumentParams := protocol.ShowDocumentParams{
 			URI:       uri,
 			External:  true,
@@ -908,6 +1295,19 @@ func documentGVK(doc string) (GVK, bool) {
 	return gvk, true
 }
 
+// Return the schema of a document from its kind and apiVersion
+func documentSchema(doc string) ([]byte, bool) {
+	gvk, ok := documentGVK(doc)
+	if !ok || gvk.kind == "" || gvk.version == "" {
+		return nil, false
+	}
+	schema, err := readSchema(gvkToSchemaId(gvk.group, gvk.version, gvk.kind) + ".json")
+	if err != nil {
+		return nil, false
+	}
+	return schema, true
+}
+
 func documentKindAndApiVersion(doc string) (string, string) {
 	// Get the kind and apiVersion from a potentially invalid yaml document
 	var kind, apiVersion string
@@ -1216,105 +1616,3 @@ func (m *Mux) handleRequestResponse(req Request) {
 		m.error(fmt.Errorf("Failed to response: %w", err))
 	}
 }
-
-func schemaFill(rootSchemaBytes []byte, path string) (string, error) {
-	schemaAtPath := rootSchemaBytes
-	if path != "." {
-		schemaPath := pathToSchemaPath(path)
-		schemaAtPathResult := gjson.GetBytes(rootSchemaBytes, schemaPath)
-		if !schemaAtPathResult.Exists() {
-			return "", fmt.Errorf("no schema found at path `%s`", schemaPath)
-		}
-		schemaAtPath = []byte(schemaAtPathResult.Raw)
-	}
-
-	var schema Schema
-	if err := json.Unmarshal(schemaAtPath, &schema); err != nil {
-		return "", fmt.Errorf("unmarshal schema: %s", err)
-	}
-
-	var err error
-	var result any
-
-	switch {
-	case len(schema.Properties) > 0:
-		concreteResult := map[string]any{}
-		for prop, subSchema := range schema.Properties {
-			concreteResult[prop] = schemaZeroValue(subSchema)
-		}
-		result = concreteResult
-	case schema.Items != nil:
-		if len(schema.Items.Properties) > 0 {
-			concreteResult := map[string]any{}
-			for prop, subSchema := range schema.Items.Properties {
-				concreteResult[prop] = schemaZeroValue(subSchema)
-			}
-			result = []any{concreteResult}
-		} else {
-			result = []any{schemaZeroValue(*schema.Items)}
-		}
-	default:
-		result = schemaZeroValue(schema)
-	}
-
-	yamlBytes, err := yaml.Marshal(result)
-	if err != nil {
-		return "", fmt.Errorf("marshal filled schema to yaml: %s", err)
-	}
-	return string(yamlBytes), nil
-}
-
-func schemaZeroValue(s Schema) any {
-	switch {
-	case s.Properties != nil:
-		return map[string]any{}
-	case len(s.Enum) > 0:
-		result := s.Enum[0]
-		switch e := result.(type) {
-		case float64:
-			result = e
-			if s.Type.One == "integer" || slices.Contains(s.Type.Many, "integer") {
-				result = int(e)
-			}
-		}
-		return result
-	case s.Const != "":
-		return s.Const
-	case s.Type.One != "":
-		return typeZeroValue(s.Type.One)
-	case len(s.Type.Many) > 0:
-		for _, t := range s.Type.Many {
-			if t != gojsonschema.TYPE_NULL {
-				return typeZeroValue(t)
-			}
-		}
-		return nil
-	case len(s.AnyOf) > 0:
-		return schemaZeroValue(s.AnyOf[0])
-	case len(s.OneOf) > 0:
-		return schemaZeroValue(s.OneOf[0])
-	default:
-		panic(fmt.Sprintf("zero value for schema not implemented. schema:\n`%#v`", s))
-	}
-}
-
-func typeZeroValue(t string) any {
-	switch t {
-	case gojsonschema.TYPE_BOOLEAN:
-		return false
-	case gojsonschema.TYPE_INTEGER:
-		return 0
-	case gojsonschema.TYPE_NUMBER:
-		return 0
-	case gojsonschema.TYPE_NULL:
-		return nil
-	case gojsonschema.TYPE_OBJECT:
-		return map[string]any{}
-	case gojsonschema.TYPE_STRING:
-		return ""
-	case gojsonschema.TYPE_ARRAY:
-		return []any{}
-	default:
-		panic(fmt.Sprintf("zero value for type `%s` not implemented", t))
-	}
-}
@@ -2,6 +2,9 @@ package main
 
 import (
 	_ "embed"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -134,6 +137,26 @@ spec:
 				},
 			},
 		},
+		"two-documents/blank-lines": {
+			contents: `kind: [
+
+---
+
+kind: [
+`,
+			errors: []ValidationError{
+				{
+					Range:    newRange(0, 0, 2, 0),
+					Type:     "invalid_yaml",
+					Severity: SEVERITY_ERROR,
+				},
+				{
+					Range:    newRange(3, 0, 5, 0),
+					Type:     "invalid_yaml",
+					Severity: SEVERITY_ERROR,
+				},
+			},
+		},
 		"one-document/no-kind-and-apiVersion": {
 			contents: `hej: du
 `,
@@ -178,6 +201,99 @@ hej: du
 	}
 }
 
+func TestOtherVersionsWithSchema(t *testing.T) {
+	dbDir, k8sVersion := DB_DIR, K8S_VERSION
+	DB_DIR = t.TempDir()
+	t.Cleanup(func() {
+		DB_DIR = dbDir
+		setKubernetesVersion(k8sVersion)
+	})
+	schemas := map[string][]string{
+		"1.21.0": {"Ingress_extensions_v1beta1.json", "Ingress_networking.k8s.io_v1.json"},
+		"1.29.0": {"Ingress_networking.k8s.io_v1.json"},
+		"master": {"Ingress_networking.k8s.io_v1.json"},
+	}
+	for version, basenames := range schemas {
+		if err := os.MkdirAll(filepath.Join(DB_DIR, version), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, basename := range basenames {
+			if err := os.WriteFile(filepath.Join(DB_DIR, version, basename), []byte("{}"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	tests := map[string]struct {
+		version, basename string
+		versions          []string
+	}{
+		"removed": {
+			version:  "v1.29.0",
+			basename: "Ingress_extensions_v1beta1.json",
+			versions: []string{"1.21.0"},
+		},
+		"in-all": {
+			version:  "1.29.0",
+			basename: "Ingress_networking.k8s.io_v1.json",
+			versions: []string{"1.21.0", "master"},
+		},
+		"in-none": {
+			version:  "master",
+			basename: "Ingress_does-not-exist_v1.json",
+			versions: nil,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			setKubernetesVersion(test.version)
+			versions := otherVersionsWithSchema(test.basename)
+			if !slices.Equal(versions, test.versions) {
+				t.Fatalf("expected %v, got %v", test.versions, versions)
+			}
+		})
+	}
+}
+
+func TestMigrateFlatDb(t *testing.T) {
+	dbDir := DB_DIR
+	DB_DIR = t.TempDir()
+	t.Cleanup(func() { DB_DIR = dbDir })
+	files := map[string]string{
+		"Service_v1.json":        "flat",
+		"Pod_v1.json":            "flat",
+		"master/Pod_v1.json":     "versioned",
+		"1.29.0/Service_v1.json": "1.29.0",
+	}
+	for name, contents := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(DB_DIR, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(DB_DIR, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := migrateFlatDb(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"master/Service_v1.json": "flat",
+		"master/Pod_v1.json":     "versioned",
+		"1.29.0/Service_v1.json": "1.29.0",
+	}
+	for name, contents := range expected {
+		b, err := os.ReadFile(filepath.Join(DB_DIR, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != contents {
+			t.Fatalf("expected %s to contain %s, got %s", name, contents, b)
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(DB_DIR, "*.json")); len(matches) > 0 {
+		t.Fatalf("expected no schemas left in the db dir, got %v", matches)
+	}
+}
+
 //go:embed testdata/enum.json
 var enum string
 
@@ -212,7 +328,7 @@ func TestFillDocument(t *testing.T) {
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			filled, err := schemaFill([]byte(test.schema), test.path)
+			filled, err := schemaFill([]byte(test.schema), test.path, FillOptions{})
 			if err != nil {
 				t.Fatalf("unexpected error: %s", err)
 			}
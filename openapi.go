@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Import the schemas that a cluster serves at /openapi/v3 or /openapi/v2. `source` is a url or a file with
+// either a swagger 2.0 document, an OpenAPI 3 document for one group version, or the /openapi/v3 index.
+// The documents in the index are downloaded when `source` is a url.
+func importOpenApi(source string) ([]string, error) {
+	body, err := fetch(source)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %s", source, err)
+	}
+	documents := [][]byte{body}
+	if paths, isIndex := openApiIndex(body); isIndex {
+		if !isRemoteUrl(source) {
+			return nil, fmt.Errorf("%s is the /openapi/v3 index, the documents it lists can only be downloaded when importing from a url", source)
+		}
+		base, err := url.Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("parse url %s: %s", source, err)
+		}
+		documents = nil
+		for _, path := range slices.Sorted(maps.Keys(paths)) {
+			relative, err := url.Parse(paths[path])
+			if err != nil {
+				return nil, fmt.Errorf("parse url for %s: %s", path, err)
+			}
+			body, err := httpGet(base.ResolveReference(relative).String())
+			if err != nil {
+				return nil, fmt.Errorf("get %s: %s", path, err)
+			}
+			documents = append(documents, body)
+		}
+	}
+	schemas := map[string][]byte{}
+	for _, doc := range documents {
+		docSchemas, err := openApiSchemas(doc)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(schemas, docSchemas)
+	}
+	if len(schemas) == 0 {
+		return nil, errors.New("no schemas for kinds found")
+	}
+	return writeImportedSchemas(schemas, source)
+}
+
+// Return the urls of the documents per group version if `doc` is the /openapi/v3 index
+func openApiIndex(doc []byte) (map[string]string, bool) {
+	var index struct {
+		Paths map[string]struct {
+			ServerRelativeURL string `json:"serverRelativeURL"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(doc, &index); err != nil {
+		return nil, false
+	}
+	paths := map[string]string{}
+	for path, p := range index.Paths {
+		if p.ServerRelativeURL != "" {
+			paths[path] = p.ServerRelativeURL
+		}
+	}
+	return paths, len(paths) > 0
+}
+
+// Return a standalone schema, with all references resolved, for each kind in a swagger 2.0 or OpenAPI 3
+// document. The schemas don't allow additional properties, like the ones in the db.
+func openApiSchemas(doc []byte) (map[string][]byte, error) {
+	var spec struct {
+		Swagger     string         `json:"swagger"`
+		Definitions map[string]any `json:"definitions"`
+		Components  struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		return nil, fmt.Errorf("unmarshal openapi document: %s", err)
+	}
+	definitions, refPrefix := spec.Components.Schemas, "#/components/schemas/"
+	if spec.Swagger != "" {
+		definitions, refPrefix = spec.Definitions, "#/definitions/"
+	}
+	if len(definitions) == 0 {
+		return nil, errors.New("expected a swagger 2.0 document with definitions or an OpenAPI 3 document with components")
+	}
+
+	schemas := map[string][]byte{}
+	for name, definition := range definitions {
+		d, ok := definition.(map[string]any)
+		if !ok {
+			continue
+		}
+		gvks, _ := d["x-kubernetes-group-version-kind"].([]any)
+		if len(gvks) != 1 || strings.Contains(name, "apimachinery") {
+			continue
+		}
+		gvk, _ := gvks[0].(map[string]any)
+		group, _ := gvk["group"].(string)
+		version, _ := gvk["version"].(string)
+		kind, _ := gvk["kind"].(string)
+		if version == "" || kind == "" {
+			continue
+		}
+		resolved := resolveRefs(d, definitions, refPrefix, []string{name})
+		schema := convertCrdSchema(resolved).(map[string]any)
+		if properties, ok := schema["properties"].(map[string]any); ok {
+			apiVersion := version
+			if group != "" {
+				apiVersion = group + "/" + version
+			}
+			properties["apiVersion"] = map[string]any{"type": "string", "enum": []any{apiVersion}}
+			properties["kind"] = map[string]any{"type": "string", "enum": []any{kind}}
+		}
+		b, err := json.Marshal(schema)
+		if err != nil {
+			return nil, fmt.Errorf("marshal schema for %s: %s", name, err)
+		}
+		schemas[gvkToSchemaId(group, version, kind)+".json"] = b
+	}
+	return schemas, nil
+}
+
+// Inline all `$ref`s. References that are already being resolved, i.e. recursive definitions such as
+// JSONSchemaProps, are replaced with an empty schema. The name of the definition is kept in
+// `x-yamlls-ref`, for the inlay hints.
+func resolveRefs(node any, definitions map[string]any, refPrefix string, resolving []string) any {
+	switch n := node.(type) {
+	case map[string]any:
+		result := map[string]any{}
+		if ref, ok := n["$ref"].(string); ok {
+			name := strings.TrimPrefix(ref, refPrefix)
+			definition, found := definitions[name]
+			if found && !slices.Contains(resolving, name) {
+				resolved := resolveRefs(definition, definitions, refPrefix, slices.Concat(resolving, []string{name}))
+				if r, ok := resolved.(map[string]any); ok {
+					maps.Copy(result, r)
+				}
+			}
+			if strings.HasSuffix(name, "resource.Quantity") {
+				result = map[string]any{"oneOf": []any{map[string]any{"type": "string"}, map[string]any{"type": "number"}}}
+			}
+			if found {
+				result["x-yamlls-ref"] = name
+			}
+		}
+		for key, value := range n {
+			if key == "$ref" {
+				continue
+			}
+			result[key] = resolveRefs(value, definitions, refPrefix, resolving)
+		}
+		if n["format"] == "int-or-string" {
+			result["x-kubernetes-int-or-string"] = true
+		}
+		if allOf, ok := result["allOf"].([]any); ok && len(allOf) == 1 {
+			// OpenAPI 3 wraps references in allOf to be able to set a description or default
+			delete(result, "allOf")
+			if inner, ok := allOf[0].(map[string]any); ok {
+				for key, value := range inner {
+					if _, found := result[key]; !found {
+						result[key] = value
+					}
+				}
+			}
+		}
+		return result
+	case []any:
+		result := make([]any, len(n))
+		for i, e := range n {
+			result[i] = resolveRefs(e, definitions, refPrefix, resolving)
+		}
+		return result
+	default:
+		return node
+	}
+}
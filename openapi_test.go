@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestOpenApiSchemas(t *testing.T) {
+	doc, err := os.ReadFile("testdata/openapi-v2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas, err := openApiSchemas(doc)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(schemas) != 1 {
+		t.Fatalf("expected only a schema for Deployment, got %d schemas", len(schemas))
+	}
+	schema, found := schemas["Deployment_apps_v1.json"]
+	if !found {
+		t.Fatalf("expected a schema for Deployment")
+	}
+	schemaCache["Deployment_apps_v1.json"] = schema
+	t.Cleanup(func() { delete(schemaCache, "Deployment_apps_v1.json") })
+
+	tests := map[string]struct {
+		contents string
+		errors   []ValidationError
+	}{
+		"valid": {
+			contents: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: myapp
+  ownerReferences:
+    - name: owner
+spec:
+  replicas: 2
+  selector:
+    app: myapp
+  strategy:
+    maxSurge: 25%
+  resources:
+    cpu: 1
+    memory: 1Gi
+`,
+			errors: nil,
+		},
+		"int-or-string": {
+			contents: `apiVersion: apps/v1
+kind: Deployment
+spec:
+  selector: {}
+  strategy:
+    maxSurge: 1
+`,
+			errors: nil,
+		},
+		"additional-property": {
+			contents: `apiVersion: apps/v1
+kind: Deployment
+spec:
+  selector: {}
+  replica: 2
+`,
+			errors: []ValidationError{
+				{
+					Range: newRange(4, 2, 4, 9),
+					Type:  "additional_property_not_allowed",
+				},
+			},
+		},
+		"required": {
+			contents: `apiVersion: apps/v1
+kind: Deployment
+spec:
+  replicas: 2
+`,
+			errors: []ValidationError{
+				{
+					Range: newRange(2, 0, 2, 4),
+					Type:  "required",
+				},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			errors, fail := fileValidate(test.contents)
+			if fail != VALIDATION_FAILURE_REASON_NOT_A_FAILURE {
+				t.Fatalf("expected validation to work, got %s", fail)
+			}
+			if len(errors) != len(test.errors) {
+				t.Fatalf("expected %d errors, got %v", len(test.errors), errors)
+			}
+			for i := range errors {
+				if errors[i].Type != test.errors[i].Type {
+					t.Fatalf("expected type `%s`, got `%s`", test.errors[i].Type, errors[i].Type)
+				}
+				if errors[i].Range != test.errors[i].Range {
+					t.Fatalf("expected range %v, got %v", test.errors[i].Range, errors[i].Range)
+				}
+			}
+		})
+	}
+}
+
+func TestImportOpenApi(t *testing.T) {
+	dbDir := DB_DIR
+	DB_DIR = t.TempDir()
+	t.Cleanup(func() { DB_DIR = dbDir })
+	batchV1, err := os.ReadFile("testdata/openapi-v3-batch-v1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openapi/v3", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"paths": {"apis/batch/v1": {"serverRelativeURL": "/openapi/v3/apis/batch/v1?hash=ABC"}}}`)
+	})
+	mux.HandleFunc("/openapi/v3/apis/batch/v1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(batchV1)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	basenames, err := importOpenApi(server.URL + "/openapi/v3")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !slices.Equal(basenames, []string{"Job_batch_v1.json"}) {
+		t.Fatalf("expected a schema for Job, got %v", basenames)
+	}
+	schema, err := os.ReadFile(filepath.Join(schemaDir(), "Job_batch_v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := gjson.GetBytes(schema, "properties.spec")
+	if spec.Get("description").String() != "Specification of the desired behavior of a job." || !spec.Get("properties.backoffLimit").Exists() {
+		t.Fatalf("expected the reference to JobSpec to be resolved, got %s", spec.Raw)
+	}
+
+	if _, err := importOpenApi("testdata/openapi-v2.json"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ids, err := dbSchemaIds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ids, []string{"Deployment_apps_v1.json", "Job_batch_v1.json"}) {
+		t.Fatalf("expected schemas for Deployment and Job, got %v", ids)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// The result of validating one file
+type FileErrors struct {
+	Filename string
+	Errors   []ValidationError
+}
+
+const (
+	OUTPUT_FORMAT_TEXT   = "text"
+	OUTPUT_FORMAT_JSON   = "json"
+	OUTPUT_FORMAT_SARIF  = "sarif"
+	OUTPUT_FORMAT_JUNIT  = "junit"
+	OUTPUT_FORMAT_GITHUB = "github"
+)
+
+var outputFormats = []string{OUTPUT_FORMAT_TEXT, OUTPUT_FORMAT_JSON, OUTPUT_FORMAT_SARIF, OUTPUT_FORMAT_JUNIT, OUTPUT_FORMAT_GITHUB}
+
+func (s Severity) name() string {
+	if s == SEVERITY_WARN {
+		return "warning"
+	}
+	return "error"
+}
+
+func parseSeverity(s string) (Severity, error) {
+	switch s {
+	case "error":
+		return SEVERITY_ERROR, nil
+	case "warn", "warning":
+		return SEVERITY_WARN, nil
+	}
+	return 0, fmt.Errorf("unknown severity `%s`, expected error or warn", s)
+}
+
+// True if `s` is at least as severe as `threshold`
+func (s Severity) atLeast(threshold Severity) bool {
+	return s <= threshold
+}
+
+// Returned by a subcommand to exit with `Code` without printing anything more
+type ExitCodeError struct{ Code int }
+
+func (e ExitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Write the validation errors in `format`. Errors at least as severe as `failOn` are reported as failures
+// in the junit format.
+func writeValidationErrors(w io.Writer, format string, results []FileErrors, failOn Severity) error {
+	switch format {
+	case OUTPUT_FORMAT_TEXT:
+		for _, result := range results {
+			for _, e := range result.Errors {
+				fmt.Fprintf(w, "%s:%d:%s\n", result.Filename, e.Range.Start.Line+1, e.Message)
+			}
+		}
+		return nil
+	case OUTPUT_FORMAT_JSON:
+		return writeJson(w, jsonErrors(results))
+	case OUTPUT_FORMAT_SARIF:
+		return writeJson(w, sarifLog(results))
+	case OUTPUT_FORMAT_JUNIT:
+		return writeJunit(w, results, failOn)
+	case OUTPUT_FORMAT_GITHUB:
+		for _, result := range results {
+			for _, e := range result.Errors {
+				fmt.Fprintln(w, githubAnnotation(result.Filename, e))
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format `%s`, expected one of %s", format, strings.Join(outputFormats, ", "))
+}
+
+func writeJson(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// Lines and columns are one-based in all formats
+type JsonError struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Message   string `json:"message"`
+	Type      string `json:"type"`
+	Severity  string `json:"severity"`
+	SchemaId  string `json:"schemaId,omitempty"`
+}
+
+func jsonErrors(results []FileErrors) []JsonError {
+	errors := []JsonError{}
+	for _, result := range results {
+		for _, e := range result.Errors {
+			errors = append(errors, JsonError{
+				File:      result.Filename,
+				Line:      e.Range.Start.Line + 1,
+				Column:    e.Range.Start.Char + 1,
+				EndLine:   e.Range.End.Line + 1,
+				EndColumn: e.Range.End.Char + 1,
+				Message:   e.Message,
+				Type:      e.Type,
+				Severity:  e.Severity.name(),
+				SchemaId:  e.SchemaId,
+			})
+		}
+	}
+	return errors
+}
+
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html, only the parts that code scanning uses
+func sarifLog(results []FileErrors) map[string]any {
+	var ruleIds []string
+	sarifResults := []any{}
+	for _, result := range results {
+		for _, e := range result.Errors {
+			if !slices.Contains(ruleIds, e.Type) {
+				ruleIds = append(ruleIds, e.Type)
+			}
+			sarifResult := map[string]any{
+				"ruleId":  e.Type,
+				"level":   e.Severity.name(),
+				"message": map[string]any{"text": e.Message},
+				"locations": []any{map[string]any{
+					"physicalLocation": map[string]any{
+						"artifactLocation": map[string]any{"uri": result.Filename},
+						"region": map[string]any{
+							"startLine":   e.Range.Start.Line + 1,
+							"startColumn": e.Range.Start.Char + 1,
+							"endLine":     e.Range.End.Line + 1,
+							"endColumn":   e.Range.End.Char + 1,
+						},
+					},
+				}},
+			}
+			if e.SchemaId != "" {
+				sarifResult["properties"] = map[string]any{"schemaId": e.SchemaId}
+			}
+			sarifResults = append(sarifResults, sarifResult)
+		}
+	}
+	slices.Sort(ruleIds)
+	rules := []any{}
+	for _, id := range ruleIds {
+		rules = append(rules, map[string]any{"id": id})
+	}
+	return map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []any{map[string]any{
+			"tool": map[string]any{"driver": map[string]any{
+				"name":           "yamlls",
+				"informationUri": "https://github.com/slarwise/yamlls",
+				"rules":          rules,
+			}},
+			"results": sarifResults,
+		}},
+	}
+}
+
+type JunitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []JunitTestSuite `xml:"testsuite"`
+}
+
+type JunitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []JunitTestCase `xml:"testcase"`
+}
+
+type JunitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *JunitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type JunitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// One test case per file, which fails if it has errors at least as severe as `failOn`. The other errors
+// are listed in the output of the test case.
+func writeJunit(w io.Writer, results []FileErrors, failOn Severity) error {
+	suite := JunitTestSuite{Name: "yamlls", Tests: len(results)}
+	for _, result := range results {
+		testCase := JunitTestCase{Name: result.Filename, ClassName: "yamlls"}
+		var failures, other []string
+		var failureTypes []string
+		for _, e := range result.Errors {
+			line := fmt.Sprintf("%s:%d:%d: %s: %s (%s)", result.Filename, e.Range.Start.Line+1, e.Range.Start.Char+1, e.Severity.name(), e.Message, e.Type)
+			if e.Severity.atLeast(failOn) {
+				failures = append(failures, line)
+				if !slices.Contains(failureTypes, e.Type) {
+					failureTypes = append(failureTypes, e.Type)
+				}
+			} else {
+				other = append(other, line)
+			}
+		}
+		if len(failures) > 0 {
+			suite.Failures++
+			testCase.Failure = &JunitFailure{
+				Message: fmt.Sprintf("%d validation errors", len(failures)),
+				Type:    strings.Join(failureTypes, ","),
+				Text:    strings.Join(failures, "\n"),
+			}
+		}
+		testCase.SystemOut = strings.Join(other, "\n")
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(JunitTestSuites{Suites: []JunitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions#setting-an-error-message
+func githubAnnotation(filename string, e ValidationError) string {
+	escapeData := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	escapeProperty := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+	command := "error"
+	if e.Severity == SEVERITY_WARN {
+		command = "warning"
+	}
+	return fmt.Sprintf("::%s file=%s,line=%d,col=%d,endLine=%d,endColumn=%d,title=%s::%s",
+		command,
+		escapeProperty.Replace(filename),
+		e.Range.Start.Line+1,
+		e.Range.Start.Char+1,
+		e.Range.End.Line+1,
+		e.Range.End.Char+1,
+		escapeProperty.Replace(e.Type),
+		escapeData.Replace(e.Message),
+	)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var outputResults = []FileErrors{
+	{
+		Filename: "deploy/service.yaml",
+		Errors: []ValidationError{
+			{Range: newRange(3, 2, 3, 10), Message: "Additional property replica is not allowed", Type: "additional_property_not_allowed", Severity: SEVERITY_ERROR, SchemaId: "Service_v1"},
+			{Range: newRange(1, 12, 1, 30), Message: "Ingress extensions/v1beta1 was removed in kubernetes 1.22, use networking.k8s.io/v1", Type: "deprecated_api_version", Severity: SEVERITY_WARN},
+		},
+	},
+	{Filename: "deploy/valid.yaml"},
+}
+
+func TestWriteValidationErrors(t *testing.T) {
+	tests := map[string]struct {
+		format string
+		failOn Severity
+		output string
+	}{
+		"text": {
+			format: OUTPUT_FORMAT_TEXT,
+			output: `deploy/service.yaml:4:Additional property replica is not allowed
+deploy/service.yaml:2:Ingress extensions/v1beta1 was removed in kubernetes 1.22, use networking.k8s.io/v1
+`,
+		},
+		"github": {
+			format: OUTPUT_FORMAT_GITHUB,
+			output: `::error file=deploy/service.yaml,line=4,col=3,endLine=4,endColumn=11,title=additional_property_not_allowed::Additional property replica is not allowed
+::warning file=deploy/service.yaml,line=2,col=13,endLine=2,endColumn=31,title=deprecated_api_version::Ingress extensions/v1beta1 was removed in kubernetes 1.22, use networking.k8s.io/v1
+`,
+		},
+		"junit-fail-on-error": {
+			format: OUTPUT_FORMAT_JUNIT,
+			failOn: SEVERITY_ERROR,
+			output: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="yamlls" tests="2" failures="1">
+    <testcase name="deploy/service.yaml" classname="yamlls">
+      <failure message="1 validation errors" type="additional_property_not_allowed">deploy/service.yaml:4:3: error: Additional property replica is not allowed (additional_property_not_allowed)</failure>
+      <system-out>deploy/service.yaml:2:13: warning: Ingress extensions/v1beta1 was removed in kubernetes 1.22, use networking.k8s.io/v1 (deprecated_api_version)</system-out>
+    </testcase>
+    <testcase name="deploy/valid.yaml" classname="yamlls"></testcase>
+  </testsuite>
+</testsuites>
+`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := writeValidationErrors(&b, test.format, outputResults, test.failOn); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if b.String() != test.output {
+				t.Fatalf("expected\n%s\ngot\n%s", test.output, b.String())
+			}
+		})
+	}
+}
+
+func TestWriteValidationErrorsJson(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeValidationErrors(&b, OUTPUT_FORMAT_JSON, outputResults, SEVERITY_ERROR); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var errors []JsonError
+	if err := json.Unmarshal(b.Bytes(), &errors); err != nil {
+		t.Fatalf("expected valid json: %s", err)
+	}
+	expected := JsonError{File: "deploy/service.yaml", Line: 4, Column: 3, EndLine: 4, EndColumn: 11, Message: "Additional property replica is not allowed", Type: "additional_property_not_allowed", Severity: "error", SchemaId: "Service_v1"}
+	if len(errors) != 2 || errors[0] != expected || errors[1].Severity != "warning" {
+		t.Fatalf("expected the errors with one-based positions, got %+v", errors)
+	}
+}
+
+func TestWriteValidationErrorsSarif(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeValidationErrors(&b, OUTPUT_FORMAT_SARIF, outputResults, SEVERITY_ERROR); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						Id string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleId    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							Uri string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine   int `json:"startLine"`
+							StartColumn int `json:"startColumn"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &log); err != nil {
+		t.Fatalf("expected valid json: %s", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Tool.Driver.Rules) != 2 || len(log.Runs[0].Results) != 2 {
+		t.Fatalf("expected one run with two rules and two results, got %s", b.String())
+	}
+	result := log.Runs[0].Results[1]
+	location := result.Locations[0].PhysicalLocation
+	if result.RuleId != "deprecated_api_version" || result.Level != "warning" || location.ArtifactLocation.Uri != "deploy/service.yaml" || location.Region.StartLine != 2 || location.Region.StartColumn != 13 {
+		t.Fatalf("expected a warning for the deprecated api version, got %+v", result)
+	}
+}
+
+func TestGithubAnnotationEscaping(t *testing.T) {
+	annotation := githubAnnotation("a,b.yaml", ValidationError{Message: "100% wrong\nreally", Type: "invalid_yaml"})
+	if !strings.HasPrefix(annotation, "::error file=a%2Cb.yaml,") || !strings.HasSuffix(annotation, "::100%25 wrong%0Areally") {
+		t.Fatalf("expected the file and message to be escaped, got %s", annotation)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -126,3 +128,63 @@ func pathToSchemaPath(path string) string {
 	}
 	return strings.Join(schemaSegments, ".")
 }
+
+// Parse a document with comments, return the body of it
+func parseYamlDocument(doc string) (ast.Node, error) {
+	file, err := yamlparser.ParseBytes([]byte(doc), yamlparser.ParseComments, yamlparser.AllowDuplicateMapKey())
+	if err != nil {
+		return nil, fmt.Errorf("parse document: %s", err)
+	}
+	if len(file.Docs) != 1 {
+		return nil, fmt.Errorf("expected 1 document, got %d", len(file.Docs))
+	}
+	return unwrapYamlNode(file.Docs[0].Body), nil
+}
+
+// Return the node at `path`, e.g. `.spec.ports.0`. If the path ends with a key, `key` is the key and
+// value of the node and `siblings` are the keys in the same mapping.
+func yamlNodeAtPath(node ast.Node, path string) (key *ast.MappingValueNode, siblings []*ast.MappingValueNode, value ast.Node, found bool) {
+	value = node
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '.' }) {
+		key, siblings = nil, nil
+		switch n := value.(type) {
+		case *ast.MappingNode:
+			siblings = n.Values
+		case *ast.MappingValueNode:
+			siblings = []*ast.MappingValueNode{n}
+		case *ast.SequenceNode:
+			if i, err := strconv.Atoi(segment); err == nil && i < len(n.Values) {
+				value = unwrapYamlNode(n.Values[i])
+				continue
+			}
+		}
+		key = findMappingValue(siblings, segment)
+		if key == nil {
+			return nil, nil, nil, false
+		}
+		value = unwrapYamlNode(key.Value)
+	}
+	return key, siblings, value, true
+}
+
+func unwrapYamlNode(node ast.Node) ast.Node {
+	for {
+		switch n := node.(type) {
+		case *ast.TagNode:
+			node = n.Value
+		case *ast.AnchorNode:
+			node = n.Value
+		default:
+			return node
+		}
+	}
+}
+
+func findMappingValue(values []*ast.MappingValueNode, key string) *ast.MappingValueNode {
+	for _, v := range values {
+		if v.Key.GetToken().Value == key {
+			return v
+		}
+	}
+	return nil
+}
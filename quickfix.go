@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/token"
+	"github.com/tidwall/gjson"
+)
+
+// Given as the data of a diagnostic, so that quick fixes for it can be computed in a code action request
+type DiagnosticData struct {
+	SchemaId string `json:"schemaId"`
+	Path     string `json:"path"`
+}
+
+type QuickFix struct {
+	Title string
+	Edits []TextEdit
+	// The fix that most likely solves the error
+	Preferred bool
+}
+
+// The maximum number of suggestions for renames and enum values
+const MAX_QUICK_FIX_SUGGESTIONS = 5
+
+// Return the fixes for an error of type `errorType` at `path` in `doc`, which is validated against
+// `schema`. The edits are relative to the start of the document.
+func quickFixes(doc string, schema []byte, errorType, path string) []QuickFix {
+	body, err := parseYamlDocument(doc)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(doc, "\n")
+	switch errorType {
+	case "additional_property_not_allowed":
+		key, siblings, _, found := yamlNodeAtPath(body, path)
+		if !found {
+			return nil
+		}
+		fixes := renameFixes(schema, path, key, siblings)
+		remove := removeKeyFix(lines, key, siblings)
+		remove.Preferred = len(fixes) == 0
+		return append(fixes, remove)
+	case "required":
+		parent, property := splitPath(path)
+		value, err := fillSchema(schema, path, FillOptions{required: true, defaults: true})
+		if err != nil {
+			return nil
+		}
+		edits, err := fillEdits(doc, parent, yaml.MapSlice{{Key: property, Value: value}})
+		if err != nil || len(edits) == 0 {
+			return nil
+		}
+		return []QuickFix{{Title: fmt.Sprintf("Add required property `%s`", property), Edits: edits, Preferred: true}}
+	case "invalid_type":
+		_, _, value, found := yamlNodeAtPath(body, path)
+		if !found {
+			return nil
+		}
+		return convertTypeFixes(schemaAtDocumentPath(schema, path), value)
+	case "enum":
+		_, _, value, found := yamlNodeAtPath(body, path)
+		if !found {
+			return nil
+		}
+		return enumFixes(schemaAtDocumentPath(schema, path), value)
+	}
+	return nil
+}
+
+func schemaAtDocumentPath(schema []byte, path string) gjson.Result {
+	if path == "." {
+		return gjson.ParseBytes(schema)
+	}
+	return gjson.GetBytes(schema, pathToSchemaPath(path))
+}
+
+// Split `.spec.replicas` into `.spec` and `replicas`
+func splitPath(path string) (string, string) {
+	i := strings.LastIndex(path, ".")
+	if i <= 0 {
+		return ".", path[i+1:]
+	}
+	return path[:i], path[i+1:]
+}
+
+// Suggest the properties in the schema that are closest to the name of `key`, excluding the ones
+// that are already set
+func renameFixes(schema []byte, path string, key *ast.MappingValueNode, siblings []*ast.MappingValueNode) []QuickFix {
+	parent, name := splitPath(path)
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	schemaAtDocumentPath(schema, parent).Get("properties").ForEach(func(property, _ gjson.Result) bool {
+		if findMappingValue(siblings, property.String()) != nil {
+			return true
+		}
+		distance := editDistance(strings.ToLower(name), strings.ToLower(property.String()))
+		if distance <= max(1, len(name)/3) {
+			candidates = append(candidates, candidate{property.String(), distance})
+		}
+		return true
+	})
+	slices.SortStableFunc(candidates, func(a, b candidate) int { return a.distance - b.distance })
+	var fixes []QuickFix
+	for i, c := range candidates[:min(len(candidates), MAX_QUICK_FIX_SUGGESTIONS)] {
+		fixes = append(fixes, QuickFix{
+			Title:     fmt.Sprintf("Did you mean `%s`?", c.name),
+			Edits:     []TextEdit{{Range: tokenRange(key.Key.GetToken()), NewText: c.name}},
+			Preferred: i == 0,
+		})
+	}
+	return fixes
+}
+
+// Remove `key` and its value. Keys on the line of a sequence entry, `- name: a`, are replaced by the
+// next key in the entry, or by `{}` if there isn't one.
+func removeKeyFix(lines []string, key *ast.MappingValueNode, siblings []*ast.MappingValueNode) QuickFix {
+	start := key.Key.GetToken().Position
+	line, column := start.Line-1, start.Column-1
+	end := subtreeEnd(lines, line, column)
+	fix := QuickFix{Title: fmt.Sprintf("Remove `%s`", key.Key.GetToken().Value)}
+	if strings.TrimSpace(lines[line][:column]) == "" {
+		fix.Edits = []TextEdit{{Range: newRange(line, 0, end+1, 0)}}
+		return fix
+	}
+	if i := slices.Index(siblings, key); i+1 < len(siblings) {
+		next := siblings[i+1].Key.GetToken().Position
+		fix.Edits = []TextEdit{{Range: newRange(line, column, next.Line-1, next.Column-1)}}
+		return fix
+	}
+	fix.Edits = []TextEdit{{Range: newRange(line, column, end, len(lines[end])), NewText: "{}"}}
+	return fix
+}
+
+// Return the last line of the value of the key at `line` and `column`
+func subtreeEnd(lines []string, line, column int) int {
+	end := line
+	for i := line + 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if isDocumentSeparator(lines[i]) {
+			break
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(lines[i]) - len(strings.TrimLeft(lines[i], " "))
+		// Sequences don't have to be indented under their key
+		if indent < column || indent == column && !strings.HasPrefix(trimmed, "-") {
+			break
+		}
+		end = i
+	}
+	return end
+}
+
+// Convert numbers and booleans to strings and back, when the schema expects it
+func convertTypeFixes(schema gjson.Result, value ast.Node) []QuickFix {
+	var types []string
+	if t := schema.Get("type"); t.IsArray() {
+		for _, t := range t.Array() {
+			types = append(types, t.String())
+		}
+	} else if t.Exists() {
+		types = append(types, t.String())
+	}
+	t := value.GetToken()
+	var newText string
+	switch value.(type) {
+	case *ast.StringNode:
+		_, integerErr := strconv.Atoi(t.Value)
+		_, numberErr := strconv.ParseFloat(t.Value, 64)
+		switch {
+		case integerErr == nil && slices.Contains(types, "integer"), numberErr == nil && slices.Contains(types, "number"):
+			newText = t.Value
+		case (t.Value == "true" || t.Value == "false") && slices.Contains(types, "boolean"):
+			newText = t.Value
+		}
+	case *ast.IntegerNode, *ast.FloatNode, *ast.BoolNode:
+		if slices.Contains(types, "string") {
+			newText = strconv.Quote(t.Value)
+		}
+	}
+	if newText == "" {
+		return nil
+	}
+	return []QuickFix{{
+		Title:     fmt.Sprintf("Change to `%s`", newText),
+		Edits:     []TextEdit{{Range: tokenRange(t), NewText: newText}},
+		Preferred: true,
+	}}
+}
+
+// Suggest the allowed values, the ones closest to the current value first
+func enumFixes(schema gjson.Result, value ast.Node) []QuickFix {
+	t := value.GetToken()
+	if t == nil {
+		return nil
+	}
+	type candidate struct {
+		text     string
+		distance int
+	}
+	var candidates []candidate
+	for _, allowed := range schema.Get("enum").Array() {
+		b, err := yaml.Marshal(jsonValue(allowed))
+		if err != nil {
+			continue
+		}
+		text := strings.TrimSpace(string(b))
+		candidates = append(candidates, candidate{text, editDistance(strings.ToLower(t.Value), strings.ToLower(allowed.String()))})
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int { return a.distance - b.distance })
+	var fixes []QuickFix
+	for i, c := range candidates[:min(len(candidates), MAX_QUICK_FIX_SUGGESTIONS)] {
+		fixes = append(fixes, QuickFix{
+			Title:     fmt.Sprintf("Change to `%s`", c.text),
+			Edits:     []TextEdit{{Range: tokenRange(t), NewText: c.text}},
+			Preferred: i == 0,
+		})
+	}
+	return fixes
+}
+
+// The range of a scalar token, including quotes
+func tokenRange(t *token.Token) Range {
+	line, column := t.Position.Line-1, t.Position.Column-1
+	return newRange(line, column, line, column+len(strings.TrimSpace(t.Origin)))
+}
+
+// The Levenshtein distance between `a` and `b`
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var quickFixSchema = []byte(`{
+  "type": "object",
+  "properties": {
+    "apiVersion": {"type": "string"},
+    "kind": {"type": "string"},
+    "spec": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["image"],
+      "properties": {
+        "image": {"type": "string"},
+        "replicas": {"type": "integer"},
+        "paused": {"type": "boolean"},
+        "version": {"type": "string"},
+        "restartPolicy": {"type": "string", "enum": ["Always", "OnFailure", "Never"]},
+        "ports": {
+          "type": "array",
+          "items": {
+            "type": "object",
+            "additionalProperties": false,
+            "properties": {
+              "containerPort": {"type": "integer"},
+              "protocol": {"type": "string"}
+            }
+          }
+        }
+      }
+    }
+  }
+}`)
+
+func TestQuickFixes(t *testing.T) {
+	useTestSchemas(t, map[string][]byte{"Thing_example.com_v1.json": quickFixSchema})
+
+	tests := map[string]struct {
+		spec     string
+		titles   []string
+		expected string
+	}{
+		"rename": {
+			spec: `spec:
+  image: nginx
+  replica: 3 # three
+`,
+			titles: []string{"Did you mean `replicas`?", "Remove `replica`"},
+			expected: `spec:
+  image: nginx
+  replicas: 3 # three
+`,
+		},
+		"rename-in-sequence": {
+			spec: `spec:
+  image: nginx
+  ports:
+  - containerport: 80
+`,
+			titles: []string{"Did you mean `containerPort`?", "Remove `containerport`"},
+			expected: `spec:
+  image: nginx
+  ports:
+  - containerPort: 80
+`,
+		},
+		"remove": {
+			spec: `spec:
+  image: nginx
+  unknown:
+    nested: true
+  paused: false
+`,
+			titles: []string{"Remove `unknown`"},
+			expected: `spec:
+  image: nginx
+  paused: false
+`,
+		},
+		"required": {
+			spec: `spec:
+  replicas: 1
+`,
+			titles: []string{"Add required property `image`"},
+			expected: `spec:
+  replicas: 1
+  image: ""
+`,
+		},
+		"string-to-integer": {
+			spec: `spec:
+  image: nginx
+  replicas: "3"
+`,
+			titles: []string{"Change to `3`"},
+			expected: `spec:
+  image: nginx
+  replicas: 3
+`,
+		},
+		"number-to-string": {
+			spec: `spec:
+  image: nginx
+  version: 1.2
+`,
+			titles: []string{"Change to `\"1.2\"`"},
+			expected: `spec:
+  image: nginx
+  version: "1.2"
+`,
+		},
+		"enum": {
+			spec: `spec:
+  image: nginx
+  restartPolicy: always
+`,
+			titles: []string{"Change to `Always`", "Change to `Never`", "Change to `OnFailure`"},
+			expected: `spec:
+  image: nginx
+  restartPolicy: Always
+`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			doc := "apiVersion: example.com/v1\nkind: Thing\n" + test.spec
+			errors, fail := fileValidate(doc)
+			if fail != VALIDATION_FAILURE_REASON_NOT_A_FAILURE {
+				t.Fatalf("expected validation to work, got %s", fail)
+			}
+			if len(errors) != 1 {
+				t.Fatalf("expected 1 error, got %v", errors)
+			}
+			fixes := quickFixes(doc, quickFixSchema, errors[0].Type, errors[0].Path)
+			var titles []string
+			for _, fix := range fixes {
+				titles = append(titles, fix.Title)
+			}
+			if len(titles) != len(test.titles) {
+				t.Fatalf("expected fixes %v, got %v", test.titles, titles)
+			}
+			for i := range titles {
+				if titles[i] != test.titles[i] {
+					t.Fatalf("expected fixes %v, got %v", test.titles, titles)
+				}
+			}
+			if !fixes[0].Preferred {
+				t.Fatalf("expected the first fix to be preferred")
+			}
+			expected := "apiVersion: example.com/v1\nkind: Thing\n" + test.expected
+			if actual := applyTextEdits(doc, fixes[0].Edits); actual != expected {
+				t.Fatalf("expected\n%s\ngot\n%s", expected, actual)
+			}
+		})
+	}
+}
+
+func TestRemoveKeyFix(t *testing.T) {
+	tests := map[string]struct {
+		doc, path, expected string
+	}{
+		"first-key-in-entry": {
+			doc:      "ports:\n- name: http\n  port: 80\n",
+			path:     ".ports.0.name",
+			expected: "ports:\n- port: 80\n",
+		},
+		"only-key-in-entry": {
+			doc:      "ports:\n- name: http\n- port: 80\n",
+			path:     ".ports.0.name",
+			expected: "ports:\n- {}\n- port: 80\n",
+		},
+		"with-sequence": {
+			doc:      "args:\n- a\n- b\nimage: nginx\n",
+			path:     ".args",
+			expected: "image: nginx\n",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			body, err := parseYamlDocument(test.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, siblings, _, found := yamlNodeAtPath(body, test.path)
+			if !found {
+				t.Fatalf("expected %s to exist", test.path)
+			}
+			fix := removeKeyFix(strings.Split(test.doc, "\n"), key, siblings)
+			if actual := applyTextEdits(test.doc, fix.Edits); actual != test.expected {
+				t.Fatalf("expected\n%s\ngot\n%s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSubtreeEnd(t *testing.T) {
+	tests := map[string]struct {
+		file         string
+		line, column int
+		expected     int
+	}{
+		"nested":               {file: "spec:\n  replicas: 1\nkind: Pod\n", line: 0, column: 0, expected: 1},
+		"sequence":             {file: "args:\n- a\n- b\nimage: nginx\n", line: 0, column: 0, expected: 2},
+		"separator":            {file: "args:\n- a\n---\n- b\n", line: 0, column: 0, expected: 1},
+		"separator-with-space": {file: "args:\n- a\n--- \n- b\n", line: 0, column: 0, expected: 1},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if actual := subtreeEnd(strings.Split(test.file, "\n"), test.line, test.column); actual != test.expected {
+				t.Fatalf("expected %d, got %d", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := map[string]struct {
+		a, b     string
+		expected int
+	}{
+		"equal":        {a: "port", b: "port", expected: 0},
+		"missing":      {a: "replica", b: "replicas", expected: 1},
+		"substitution": {a: "image", b: "imagf", expected: 1},
+		"swapped":      {a: "contianer", b: "container", expected: 2},
+		"empty":        {a: "", b: "abc", expected: 3},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if actual := editDistance(test.a, test.b); actual != test.expected {
+				t.Fatalf("expected %d, got %d", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	NATIVE_SCHEMAS_REPO_URL = "https://raw.githubusercontent.com/yannh/kubernetes-json-schema/refs/heads/master"
+	CUSTOM_SCHEMAS_BASE_URL = "https://raw.githubusercontent.com/datreeio/CRDs-catalog/refs/heads/main"
+)
+
+var (
+	httpClient   = &http.Client{Timeout: 30 * time.Second}
+	httpRetries  = 4
+	retryBackoff = 500 * time.Millisecond
+)
+
+// The manifest is stored next to the schemas, it is used to only download schemas that have changed
+const MANIFEST_FILENAME = ".manifest.json"
+
+type Manifest map[string]ManifestEntry // basename -> entry
+
+type ManifestEntry struct {
+	Url    string `json:"url"`
+	Sha256 string `json:"sha256"`
+	ETag   string `json:"etag,omitempty"`
+	// Imported with e.g. `yamlls import-crd` rather than downloaded, these are kept when refreshing
+	Imported bool `json:"imported,omitempty"`
+}
+
+func readManifest(dir string) Manifest {
+	manifest := Manifest{}
+	b, err := os.ReadFile(filepath.Join(dir, MANIFEST_FILENAME))
+	if err != nil {
+		return manifest
+	}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return Manifest{}
+	}
+	return manifest
+}
+
+func writeManifest(dir string, manifest Manifest) error {
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, MANIFEST_FILENAME), b, 0644); err != nil {
+		return fmt.Errorf("write manifest: %s", err)
+	}
+	return nil
+}
+
+// The schemas that are done in the staging directory, one line with the basename and manifest entry per
+// schema. A refresh that is interrupted continues from them the next time.
+const JOURNAL_FILENAME = ".journal.jsonl"
+
+type JournalEntry struct {
+	Basename string `json:"basename"`
+	ManifestEntry
+}
+
+func readJournal(dir string) Manifest {
+	manifest := Manifest{}
+	b, err := os.ReadFile(filepath.Join(dir, JOURNAL_FILENAME))
+	if err != nil {
+		return manifest
+	}
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		var entry JournalEntry
+		// The last line is incomplete if the refresh stopped while writing it
+		if err := json.Unmarshal(line, &entry); err == nil {
+			manifest[entry.Basename] = entry.ManifestEntry
+		}
+	}
+	return manifest
+}
+
+type RefreshSummary struct {
+	Downloaded, Unchanged int
+	Failed                []string // The errors for the schemas that could not be downloaded
+}
+
+// Download the schemas from `sources` into a staging directory and replace the schemas for the current kubernetes version
+// when done. Schemas that haven't changed since the last refresh, according to their ETag, are copied
+// from the current schemas, imported schemas are kept. The current schemas are kept if the lists of schemas cannot be downloaded,
+// or if no schemas at all could be downloaded. The staging directory is kept until the schemas are
+// replaced, so that a refresh that is interrupted doesn't download the same schemas again.
+func refreshDatabase(sources []SchemaSource) error {
+	summary, err := refreshSchemas(sources)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "downloaded %d schemas, %d unchanged, %d failed\n", summary.Downloaded, summary.Unchanged, len(summary.Failed))
+	if len(summary.Failed) > 0 {
+		for _, f := range summary.Failed {
+			fmt.Fprintln(os.Stderr, f)
+		}
+		return fmt.Errorf("%d schemas failed to download", len(summary.Failed))
+	}
+	return nil
+}
+
+func refreshSchemas(sources []SchemaSource) (RefreshSummary, error) {
+	var summary RefreshSummary
+	dir := schemaDir()
+	staging := filepath.Join(DB_DIR, ".staging-"+K8S_VERSION)
+	if err := restoreInterruptedSwap(); err != nil {
+		return summary, err
+	}
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return summary, fmt.Errorf("create `%s`: %s", staging, err)
+	}
+	staged := readJournal(staging)
+	journal, err := os.OpenFile(filepath.Join(staging, JOURNAL_FILENAME), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return summary, fmt.Errorf("open journal: %s", err)
+	}
+	defer journal.Close()
+
+	var definitionsToDownload []Definition
+	provided := map[string]bool{}
+	for _, source := range sources {
+		definitions, err := source.definitions(K8S_VERSION)
+		if err != nil {
+			return summary, fmt.Errorf("list schemas in %s source %s: %s", source.Kind, source.baseUrl(K8S_VERSION), err)
+		}
+		for _, d := range definitions {
+			// The first source that provides a schema takes precedence
+			if provided[d.basename] {
+				continue
+			}
+			provided[d.basename] = true
+			definitionsToDownload = append(definitionsToDownload, d)
+		}
+	}
+
+	previousManifest := readManifest(dir)
+	type Result struct {
+		basename  string
+		entry     ManifestEntry
+		unchanged bool
+		err       error
+	}
+
+	worker := func(jobs <-chan Definition, results chan<- Result) {
+		var schemaCompact bytes.Buffer
+		for definition := range jobs {
+			result := Result{basename: definition.basename}
+			previousFile := filepath.Join(dir, definition.basename)
+			filename := filepath.Join(staging, definition.basename)
+
+			// Downloaded by a refresh that was interrupted
+			if entry, found := staged[definition.basename]; found && entry.Url == definition.url && fileSha256(filename) == entry.Sha256 {
+				result.entry = entry
+				results <- result
+				continue
+			}
+			var etag string
+			previous, found := previousManifest[definition.basename]
+			if found && previous.Url == definition.url && fileSha256(previousFile) == previous.Sha256 {
+				etag = previous.ETag
+			}
+			var schema []byte
+			var newEtag string
+			var err error
+			if isRemoteUrl(definition.url) {
+				schema, newEtag, err = httpGetConditional(definition.url, etag)
+			} else {
+				schema, err = fetch(definition.url)
+			}
+			switch {
+			case errors.Is(err, errNotModified):
+				if err := copyFile(previousFile, filename); err != nil {
+					result.err = fmt.Errorf("copy unchanged schema %s: %s", definition.basename, err)
+				} else {
+					result.entry = previous
+					result.unchanged = true
+				}
+			case err != nil:
+				result.err = fmt.Errorf("get schema: %s", err)
+			default:
+				schemaCompact.Reset()
+				if err := json.Compact(&schemaCompact, schema); err != nil {
+					result.err = fmt.Errorf("make schema %s more compact: %s", definition.url, err)
+				} else if err := os.WriteFile(filename, schemaCompact.Bytes(), 0644); err != nil {
+					result.err = fmt.Errorf("write schema to %s: %s", filename, err)
+				} else {
+					sum := sha256.Sum256(schemaCompact.Bytes())
+					result.entry = ManifestEntry{Url: definition.url, Sha256: hex.EncodeToString(sum[:]), ETag: newEtag}
+				}
+			}
+			results <- result
+		}
+	}
+	jobs := make(chan Definition, len(definitionsToDownload))
+	results := make(chan Result, len(definitionsToDownload))
+
+	workersCount := 50
+	for range workersCount {
+		go worker(jobs, results)
+	}
+
+	for _, d := range definitionsToDownload {
+		jobs <- d
+	}
+	close(jobs)
+
+	manifest := Manifest{}
+	var failedBasenames []string
+	for i := range len(definitionsToDownload) {
+		result := <-results
+		switch {
+		case result.err != nil:
+			summary.Failed = append(summary.Failed, result.err.Error())
+			failedBasenames = append(failedBasenames, result.basename)
+		case result.unchanged:
+			summary.Unchanged++
+			manifest[result.basename] = result.entry
+		default:
+			summary.Downloaded++
+			manifest[result.basename] = result.entry
+		}
+		if result.err == nil {
+			if b, err := json.Marshal(JournalEntry{Basename: result.basename, ManifestEntry: result.entry}); err == nil {
+				journal.Write(append(b, '\n'))
+			}
+		}
+		fmt.Fprintf(os.Stderr, "\r%4d/%d", i+1, len(definitionsToDownload))
+	}
+	fmt.Fprintln(os.Stderr)
+	slices.Sort(summary.Failed)
+
+	if len(manifest) == 0 {
+		return summary, fmt.Errorf("no schemas could be downloaded, keeping the current schemas")
+	}
+	for _, basename := range failedBasenames {
+		// Keep the previous version of schemas that failed to download
+		entry, found := previousManifest[basename]
+		if !found {
+			continue
+		}
+		if err := copyFile(filepath.Join(dir, basename), filepath.Join(staging, basename)); err == nil {
+			manifest[basename] = entry
+		}
+	}
+	for basename, entry := range previousManifest {
+		if !entry.Imported {
+			continue
+		}
+		if err := copyFile(filepath.Join(dir, basename), filepath.Join(staging, basename)); err == nil {
+			manifest[basename] = entry
+		}
+	}
+	// Schemas from an interrupted refresh that are no longer listed
+	files, err := os.ReadDir(staging)
+	if err != nil {
+		return summary, fmt.Errorf("read `%s`: %s", staging, err)
+	}
+	for _, f := range files {
+		if _, found := manifest[f.Name()]; !found && !strings.HasPrefix(f.Name(), ".") {
+			os.Remove(filepath.Join(staging, f.Name()))
+		}
+	}
+	journal.Close()
+	if err := os.Remove(filepath.Join(staging, JOURNAL_FILENAME)); err != nil {
+		return summary, fmt.Errorf("remove journal: %s", err)
+	}
+	if err := writeManifest(staging, manifest); err != nil {
+		return summary, err
+	}
+
+	// The swap is two renames, it is not atomic. If yamlls stops between them the current schemas are
+	// in `old`, restoreInterruptedSwap moves them back the next time it runs.
+	old := filepath.Join(DB_DIR, ".old-"+K8S_VERSION)
+	if err := os.RemoveAll(old); err != nil {
+		return summary, fmt.Errorf("remove `%s`: %s", old, err)
+	}
+	if err := os.Rename(dir, old); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return summary, fmt.Errorf("move current schemas: %s", err)
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		os.Rename(old, dir)
+		return summary, fmt.Errorf("move new schemas into place: %s", err)
+	}
+	if err := os.RemoveAll(old); err != nil {
+		return summary, fmt.Errorf("remove `%s`: %s", old, err)
+	}
+	return summary, nil
+}
+
+// Move back the schemas of the versions where a refresh stopped between moving away the current schemas
+// and moving the new ones into place. Remove the old schemas if it stopped after that.
+func restoreInterruptedSwap() error {
+	files, err := os.ReadDir(DB_DIR)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read db %s: %s", DB_DIR, err)
+	}
+	for _, f := range files {
+		version, found := strings.CutPrefix(f.Name(), ".old-")
+		if !found || !f.IsDir() {
+			continue
+		}
+		old, dir := filepath.Join(DB_DIR, f.Name()), filepath.Join(DB_DIR, version)
+		if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+			if err := os.Rename(old, dir); err != nil {
+				return fmt.Errorf("restore the schemas for %s: %s", version, err)
+			}
+		} else if err := os.RemoveAll(old); err != nil {
+			return fmt.Errorf("remove `%s`: %s", old, err)
+		}
+	}
+	return nil
+}
+
+var errNotModified = errors.New("not modified")
+
+func httpGet(url string) ([]byte, error) {
+	body, _, err := httpGetConditional(url, "")
+	return body, err
+}
+
+// Get `url`, retrying with an exponential backoff on network errors and server errors. If `etag` is
+// given and the resource hasn't changed, errNotModified is returned. Return the body and its ETag.
+func httpGetConditional(url, etag string) ([]byte, string, error) {
+	var err error
+	for attempt := range httpRetries + 1 {
+		if attempt > 0 {
+			time.Sleep(retryBackoff * time.Duration(1<<(attempt-1)))
+		}
+		var body []byte
+		var newEtag string
+		var retry bool
+		body, newEtag, retry, err = httpGetOnce(url, etag)
+		if err == nil || !retry {
+			return body, newEtag, err
+		}
+	}
+	return nil, "", fmt.Errorf("%s, gave up after %d retries", err, httpRetries)
+}
+
+func httpGetOnce(url, etag string) ([]byte, string, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("create request for %s: %s", url, err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", true, fmt.Errorf("get %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && etag != "":
+		return nil, etag, false, errNotModified
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, "", true, fmt.Errorf("get %s: %s", url, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", false, fmt.Errorf("get %s: %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", true, fmt.Errorf("read body: %s", err)
+	}
+	return body, resp.Header.Get("ETag"), false, nil
+}
+
+func fileSha256(filename string) string {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func copyFile(src, dst string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, b, 0644)
+}
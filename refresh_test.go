@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+type upstream struct {
+	mu           sync.Mutex
+	files        map[string]string
+	failuresLeft map[string]int // Respond with 500 this many times before succeeding
+	notModified  int
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.failuresLeft[r.URL.Path] > 0 {
+		u.failuresLeft[r.URL.Path]--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	body, found := u.files[r.URL.Path]
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	etag := fmt.Sprintf(`"%d"`, len(body))
+	if r.Header.Get("If-None-Match") == etag {
+		u.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	fmt.Fprint(w, body)
+}
+
+func TestRefreshSchemas(t *testing.T) {
+	dbDir, retries := DB_DIR, retryBackoff
+	DB_DIR = t.TempDir()
+	retryBackoff = 0
+	t.Cleanup(func() {
+		DB_DIR = dbDir
+		retryBackoff = retries
+	})
+	u := &upstream{
+		files: map[string]string{
+			"/native/_definitions.json": `{"definitions": {
+  "io.k8s.api.core.v1.Service": {"x-kubernetes-group-version-kind": [{"group": "", "kind": "Service", "version": "v1"}]},
+  "io.k8s.api.networking.v1.Ingress": {"x-kubernetes-group-version-kind": [{"group": "networking.k8s.io", "kind": "Ingress", "version": "v1"}]},
+  "io.k8s.api.core.v1.Pod": {"x-kubernetes-group-version-kind": [{"group": "", "kind": "Pod", "version": "v1"}]}
+}}`,
+			"/native/service-v1.json":                       `{ "description": "service" }`,
+			"/native/ingress-networking-v1.json":            `{ "description": "ingress" }`,
+			"/custom/index.yaml":                            "argoproj.io:\n  - apiVersion: argoproj.io/v1alpha1\n    kind: Application\n    filename: argoproj.io/application_v1alpha1.json\n",
+			"/custom/argoproj.io/application_v1alpha1.json": `{ "description": "application" }`,
+		},
+		failuresLeft: map[string]int{"/native/ingress-networking-v1.json": 2},
+	}
+	server := httptest.NewServer(u)
+	defer server.Close()
+	sources := []SchemaSource{
+		{Kind: SOURCE_KIND_DEFINITIONS, Url: server.URL + "/native"},
+		{Kind: SOURCE_KIND_INDEX, Url: server.URL + "/custom"},
+	}
+
+	summary, err := refreshSchemas(sources)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if summary.Downloaded != 3 || summary.Unchanged != 0 || len(summary.Failed) != 1 {
+		t.Fatalf("expected 3 downloaded and 1 failed, got %+v", summary)
+	}
+	ids, err := dbSchemaIds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Application_argoproj.io_v1alpha1.json", "Ingress_networking.k8s.io_v1.json", "Service_v1.json"}
+	if !slices.Equal(ids, expected) {
+		t.Fatalf("expected schemas %v, got %v", expected, ids)
+	}
+	schema, err := os.ReadFile(filepath.Join(schemaDir(), "Service_v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(schema) != `{"description":"service"}` {
+		t.Fatalf("expected the schema to be compacted, got %s", schema)
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		u.files["/native/pod-v1.json"] = `{"description": "pod"}`
+		summary, err := refreshSchemas(sources)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if summary.Downloaded != 1 || summary.Unchanged != 3 || len(summary.Failed) != 0 {
+			t.Fatalf("expected 1 downloaded and 3 unchanged, got %+v", summary)
+		}
+		if u.notModified != 3 {
+			t.Fatalf("expected 3 conditional requests, got %d", u.notModified)
+		}
+	})
+
+	t.Run("index-unavailable", func(t *testing.T) {
+		delete(u.files, "/custom/index.yaml")
+		if _, err := refreshSchemas(sources); err == nil {
+			t.Fatalf("expected an error")
+		}
+		ids, err := dbSchemaIds()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != 4 {
+			t.Fatalf("expected the current schemas to be kept, got %v", ids)
+		}
+	})
+
+	t.Run("keep-failed", func(t *testing.T) {
+		u.files["/custom/index.yaml"] = "{}"
+		u.failuresLeft["/native/service-v1.json"] = httpRetries + 1
+		summary, err := refreshSchemas(sources)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(summary.Failed) != 1 {
+			t.Fatalf("expected 1 failure, got %+v", summary)
+		}
+		if _, err := os.Stat(filepath.Join(schemaDir(), "Service_v1.json")); err != nil {
+			t.Fatalf("expected the previous version of the failed schema to be kept: %s", err)
+		}
+		if _, err := os.Stat(filepath.Join(schemaDir(), "Application_argoproj.io_v1alpha1.json")); err == nil {
+			t.Fatalf("expected schemas that are no longer listed to be removed")
+		}
+	})
+	t.Run("interrupted", func(t *testing.T) {
+		staging := filepath.Join(DB_DIR, ".staging-"+K8S_VERSION)
+		if err := os.MkdirAll(staging, 0755); err != nil {
+			t.Fatal(err)
+		}
+		staged := []byte(`{"description":"staged pod"}`)
+		if err := os.WriteFile(filepath.Join(staging, "Pod_v1.json"), staged, 0644); err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(staged)
+		entry := JournalEntry{Basename: "Pod_v1.json", ManifestEntry: ManifestEntry{Url: server.URL + "/native/pod-v1.json", Sha256: hex.EncodeToString(sum[:])}}
+		b, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(staging, JOURNAL_FILENAME), append(b, []byte("\n{\"basename\": \"Serv")...), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := refreshSchemas(sources); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		schema, err := os.ReadFile(filepath.Join(schemaDir(), "Pod_v1.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(schema) != string(staged) {
+			t.Fatalf("expected the schema from the interrupted refresh to be used, got %s", schema)
+		}
+		if _, err := os.Stat(staging); err == nil {
+			t.Fatalf("expected the staging directory to be removed after the swap")
+		}
+		if _, err := os.Stat(filepath.Join(schemaDir(), JOURNAL_FILENAME)); err == nil {
+			t.Fatalf("expected the journal to be removed")
+		}
+	})
+
+	t.Run("interrupted-swap", func(t *testing.T) {
+		if err := os.Rename(schemaDir(), filepath.Join(DB_DIR, ".old-"+K8S_VERSION)); err != nil {
+			t.Fatal(err)
+		}
+		if err := restoreInterruptedSwap(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(schemaDir(), "Pod_v1.json")); err != nil {
+			t.Fatalf("expected the schemas to be restored: %s", err)
+		}
+	})
+}
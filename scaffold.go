@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Manifests for common kinds, like `kubectl create <kind> --dry-run=client --output=yaml` without the
+// read-only fields. They are LSP snippets, `${1:name}` is a placeholder and `$1` repeats it. The name
+// is always placeholder 1.
+var scaffolds = map[string]string{
+	"ConfigMap": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ${1:name}
+data:
+  ${2:key}: ${3:value}
+`,
+	"CronJob": `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: ${1:name}
+spec:
+  schedule: "${2:0 * * * *}"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          restartPolicy: OnFailure
+          containers:
+          - name: $1
+            image: ${3:image}
+`,
+	"Deployment": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ${1:name}
+  labels:
+    app: $1
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: $1
+  template:
+    metadata:
+      labels:
+        app: $1
+    spec:
+      containers:
+      - name: $1
+        image: ${2:image}
+        ports:
+        - containerPort: ${3:8080}
+`,
+	"HorizontalPodAutoscaler": `apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: ${1:name}
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: $1
+  minReplicas: 1
+  maxReplicas: ${2:10}
+  metrics:
+  - type: Resource
+    resource:
+      name: cpu
+      target:
+        type: Utilization
+        averageUtilization: ${3:80}
+`,
+	"Ingress": `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: ${1:name}
+spec:
+  rules:
+  - host: ${2:example.com}
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: ${3:service}
+            port:
+              number: ${4:80}
+`,
+	"Job": `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: ${1:name}
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: $1
+        image: ${2:image}
+`,
+	"Namespace": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: ${1:name}
+`,
+	"PersistentVolumeClaim": `apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: ${1:name}
+spec:
+  accessModes:
+  - ReadWriteOnce
+  resources:
+    requests:
+      storage: ${2:1Gi}
+`,
+	"Secret": `apiVersion: v1
+kind: Secret
+metadata:
+  name: ${1:name}
+type: Opaque
+stringData:
+  ${2:key}: ${3:value}
+`,
+	"Service": `apiVersion: v1
+kind: Service
+metadata:
+  name: ${1:name}
+spec:
+  selector:
+    app: $1
+  ports:
+  - name: http
+    port: ${2:80}
+    targetPort: ${3:8080}
+`,
+	"ServiceAccount": `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: ${1:name}
+`,
+}
+
+func scaffoldKinds() []string {
+	return slices.Sorted(maps.Keys(scaffolds))
+}
+
+// Return a snippet with a manifest for `kind`. Kinds without a scaffold get their required fields from
+// the schema, `kind` can then also be a schema id.
+func scaffoldSnippet(kind string) (string, error) {
+	for k, snippet := range scaffolds {
+		if strings.EqualFold(k, kind) {
+			return snippet, nil
+		}
+	}
+	basename, err := findSchemaId(kind)
+	if err != nil {
+		return "", err
+	}
+	schema, err := readSchema(basename)
+	if err != nil {
+		return "", fmt.Errorf("read schema %s: %s", basename, err)
+	}
+	filled, err := fillSchema(schema, ".", FillOptions{required: true, defaults: true})
+	if err != nil {
+		return "", fmt.Errorf("fill schema %s: %s", basename, err)
+	}
+	gvk := schemaIdToGvk(basename)
+	snippet := fmt.Sprintf("apiVersion: %s\nkind: %s\nmetadata:\n  name: ${1:name}\n", gvkApiVersion(gvk), gvk.kind)
+	var rest yaml.MapSlice
+	document, _ := filled.(yaml.MapSlice)
+	for _, item := range document {
+		if item.Key != "apiVersion" && item.Key != "kind" && item.Key != "metadata" && item.Key != "status" {
+			rest = append(rest, item)
+		}
+	}
+	if len(rest) > 0 {
+		b, err := yaml.Marshal(rest)
+		if err != nil {
+			return "", fmt.Errorf("marshal filled schema to yaml: %s", err)
+		}
+		snippet += escapeSnippet(string(b))
+	}
+	return snippet, nil
+}
+
+func escapeSnippet(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `$`, `\$`, `}`, `\}`).Replace(s)
+}
+
+// Replace the placeholders in `snippet` with their default values, and placeholder 1 with `name` if it
+// is not empty
+func expandSnippet(snippet, name string) string {
+	values := map[int]string{}
+	if name != "" {
+		values[1] = name
+	}
+	var b strings.Builder
+	for i := 0; i < len(snippet); i++ {
+		c := snippet[i]
+		if c == '\\' && i+1 < len(snippet) {
+			i++
+			b.WriteByte(snippet[i])
+			continue
+		}
+		if c != '$' {
+			b.WriteByte(c)
+			continue
+		}
+		if strings.HasPrefix(snippet[i+1:], "{") {
+			end := strings.IndexByte(snippet[i:], '}')
+			if end == -1 {
+				// Not a placeholder
+				b.WriteString(snippet[i:])
+				break
+			}
+			number, value, _ := strings.Cut(snippet[i+2:i+end], ":")
+			n, _ := strconv.Atoi(number)
+			if _, found := values[n]; !found {
+				values[n] = value
+			}
+			b.WriteString(values[n])
+			i += end
+			continue
+		}
+		j := i + 1
+		for j < len(snippet) && '0' <= snippet[j] && snippet[j] <= '9' {
+			j++
+		}
+		if j == i+1 {
+			b.WriteByte(c)
+			continue
+		}
+		n, _ := strconv.Atoi(snippet[i+1 : j])
+		b.WriteString(values[n])
+		i = j - 1
+	}
+	return b.String()
+}
+
+// Return true if the document at `line` is empty, except for comments and the line itself
+func isBlankDocumentAt(file string, line int) bool {
+	lines := strings.Split(file, "\n")
+	for _, step := range []int{-1, 1} {
+		for i := line + step; 0 <= i && i < len(lines); i += step {
+			trimmed := strings.TrimSpace(lines[i])
+			if isDocumentSeparator(lines[i]) {
+				break
+			}
+			if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				return false
+			}
+		}
+	}
+	return true
+}
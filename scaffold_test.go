@@ -0,0 +1,144 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+func TestScaffolds(t *testing.T) {
+	for _, kind := range scaffoldKinds() {
+		t.Run(kind, func(t *testing.T) {
+			var manifest struct {
+				Kind     string `yaml:"kind"`
+				Metadata struct {
+					Name string `yaml:"name"`
+				} `yaml:"metadata"`
+			}
+			if err := yaml.Unmarshal([]byte(expandSnippet(scaffolds[kind], "web")), &manifest); err != nil {
+				t.Fatalf("invalid yaml: %s", err)
+			}
+			if manifest.Kind != kind || manifest.Metadata.Name != "web" {
+				t.Fatalf("expected a %s named web, got %s named %s", kind, manifest.Kind, manifest.Metadata.Name)
+			}
+		})
+	}
+}
+
+func TestExpandSnippet(t *testing.T) {
+	tests := map[string]struct {
+		snippet, name, expected string
+	}{
+		"defaults": {
+			snippet:  "name: ${1:name}\nimage: ${2:nginx}\n",
+			expected: "name: name\nimage: nginx\n",
+		},
+		"name": {
+			snippet:  "name: ${1:name}\napp: $1\n",
+			name:     "web",
+			expected: "name: web\napp: web\n",
+		},
+		"escaped": {
+			snippet:  `value: \${HOME\}`,
+			expected: "value: ${HOME}",
+		},
+		"final-tabstop": {
+			snippet:  "a: $0",
+			expected: "a: ",
+		},
+		"unclosed-placeholder": {
+			snippet:  "name: ${1:abc",
+			name:     "x",
+			expected: "name: ${1:abc",
+		},
+		"dollar": {
+			snippet:  "price: $ 5$",
+			expected: "price: $ 5$",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if actual := expandSnippet(test.snippet, test.name); actual != test.expected {
+				t.Fatalf("expected\n%s\ngot\n%s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestScaffoldSnippet(t *testing.T) {
+	useTestSchemas(t, map[string][]byte{"Widget_example.com_v1.json": []byte(`{
+  "type": "object",
+  "required": ["spec"],
+  "properties": {
+    "apiVersion": {"type": "string"},
+    "kind": {"type": "string"},
+    "metadata": {"type": "object"},
+    "spec": {
+      "type": "object",
+      "required": ["size", "command"],
+      "properties": {
+        "color": {"type": "string"},
+        "size": {"type": "integer", "default": 3},
+        "command": {"type": "string", "default": "echo ${HOME}"}
+      }
+    },
+    "status": {"type": "object"}
+  }
+}`)})
+
+	tests := map[string]struct {
+		kind, expected string
+	}{
+		"scaffold": {
+			kind:     "namespace",
+			expected: scaffolds["Namespace"],
+		},
+		"required-fields": {
+			kind: "widget",
+			expected: `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: ${1:name}
+spec:
+  size: 3
+  command: echo \${HOME\}
+`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			snippet, err := scaffoldSnippet(test.kind)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if snippet != test.expected {
+				t.Fatalf("expected\n%s\ngot\n%s", test.expected, snippet)
+			}
+		})
+	}
+	if _, err := scaffoldSnippet("Nope"); err == nil {
+		t.Fatalf("expected an error for a kind without a schema")
+	}
+}
+
+func TestIsBlankDocumentAt(t *testing.T) {
+	tests := map[string]struct {
+		file     string
+		line     int
+		expected bool
+	}{
+		"empty":            {file: "", line: 0, expected: true},
+		"typing":           {file: "# comment\nDep\n\n", line: 1, expected: true},
+		"other-document":   {file: "kind: Service\n---\nDep\n---\nkind: Pod\n", line: 2, expected: true},
+		"separator-spaces": {file: "kind: Service\n--- \nDep\n---\t\nkind: Pod\n", line: 2, expected: true},
+		"not-blank":        {file: "kind: Service\nDep\n", line: 1, expected: false},
+		"not-blank-before": {file: "Dep\n  name: web\n", line: 0, expected: false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if actual := isBlankDocumentAt(test.file, test.line); actual != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
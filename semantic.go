@@ -0,0 +1,179 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/token"
+	"github.com/tidwall/gjson"
+	"go.lsp.dev/protocol"
+)
+
+// The index of a type or modifier is the number sent to the client
+const (
+	SEMANTIC_TOKEN_PROPERTY = iota
+	SEMANTIC_TOKEN_UNKNOWN_PROPERTY
+	SEMANTIC_TOKEN_ENUM_MEMBER
+	SEMANTIC_TOKEN_REFERENCE
+)
+
+const (
+	SEMANTIC_MODIFIER_DEPRECATED = 1 << iota
+	SEMANTIC_MODIFIER_REQUIRED
+)
+
+var semanticTokensLegend = protocol.SemanticTokensLegend{
+	TokenTypes: []protocol.SemanticTokenTypes{
+		protocol.SemanticTokenProperty,
+		// Keys that aren't in the schema, so that they stand out from the known ones in most themes
+		protocol.SemanticTokenVariable,
+		protocol.SemanticTokenEnumMember,
+		protocol.SemanticTokenClass,
+	},
+	TokenModifiers: []protocol.SemanticTokenModifiers{
+		protocol.SemanticTokenModifierDeprecated,
+		"required",
+	},
+}
+
+// The protocol package lacks the legend and the full option
+type SemanticTokensOptions struct {
+	Legend protocol.SemanticTokensLegend `json:"legend"`
+	Full   bool                          `json:"full"`
+}
+
+// Keys whose value is the name of another resource
+var referenceKeys = []string{"serviceAccountName", "secretName", "claimName", "serviceName", "storageClassName", "priorityClassName", "ingressClassName", "runtimeClassName", "volumeName"}
+
+// Objects whose `name` is the name of another resource, along with the ones ending with `Ref`
+var referenceObjects = []string{"configMap", "imagePullSecrets", "service"}
+
+var deprecatedPattern = regexp.MustCompile(`^Deprecated|Deprecated:|DEPRECATED|(is|has been) deprecated`)
+
+type semanticToken struct{ line, char, length, tokenType, modifiers int }
+
+type semanticTokenCollector struct {
+	tokens    []semanticToken
+	firstLine int // the line of the document in the file
+}
+
+// Return the semantic tokens of `file` in the relative encoding of the LSP spec. Keys are known or
+// unknown properties, deprecated or required, and values are enum members or references to other
+// resources.
+func semanticTokens(file string) []uint32 {
+	c := semanticTokenCollector{}
+	for _, doc := range documentsInFile(file) {
+		body, err := parseYamlDocument(doc.document)
+		if err != nil || body == nil {
+			continue
+		}
+		schema, ok := documentSchema(doc.document)
+		if !ok {
+			continue
+		}
+		c.firstLine = doc.start
+		c.visit(body, gjson.ParseBytes(schema), "", false)
+	}
+	return encodeSemanticTokens(c.tokens)
+}
+
+// Visit a node at `schema`, `key` is the key of the node or of the sequence it is in. `reference` is
+// true if the node is the name of another resource.
+func (c *semanticTokenCollector) visit(node ast.Node, schema gjson.Result, key string, reference bool) {
+	switch n := unwrapYamlNode(node).(type) {
+	case *ast.MappingNode:
+		c.visitMapping(n.Values, schema, key)
+	case *ast.MappingValueNode:
+		c.visitMapping([]*ast.MappingValueNode{n}, schema, key)
+	case *ast.SequenceNode:
+		for _, item := range n.Values {
+			c.visit(item, schema.Get("items"), key, false)
+		}
+	case nil:
+	default:
+		switch {
+		case schema.Get("enum").Exists():
+			c.add(n.GetToken(), SEMANTIC_TOKEN_ENUM_MEMBER, 0)
+		case reference:
+			c.add(n.GetToken(), SEMANTIC_TOKEN_REFERENCE, 0)
+		}
+	}
+}
+
+func (c *semanticTokenCollector) visitMapping(values []*ast.MappingValueNode, schema gjson.Result, parent string) {
+	var required []string
+	for _, r := range schema.Get("required").Array() {
+		required = append(required, r.String())
+	}
+	additional := schema.Get("additionalProperties")
+	for _, value := range values {
+		key := value.Key.GetToken().Value
+		property := schema.Get("properties").Get(gjson.Escape(key))
+		switch {
+		case property.Exists():
+			modifiers := 0
+			if isDeprecatedSchema(property) {
+				modifiers |= SEMANTIC_MODIFIER_DEPRECATED
+			}
+			if slices.Contains(required, key) {
+				modifiers |= SEMANTIC_MODIFIER_REQUIRED
+			}
+			c.add(value.Key.GetToken(), SEMANTIC_TOKEN_PROPERTY, modifiers)
+			reference := slices.Contains(referenceKeys, key) || key == "name" && isReferenceObject(parent)
+			c.visit(value.Value, property, key, reference)
+		case additional.Type == gjson.False:
+			c.add(value.Key.GetToken(), SEMANTIC_TOKEN_UNKNOWN_PROPERTY, 0)
+		case additional.IsObject():
+			c.visit(value.Value, additional, key, false)
+		}
+	}
+}
+
+// Add a token for a scalar, tokens can't span several lines
+func (c *semanticTokenCollector) add(t *token.Token, tokenType, modifiers int) {
+	if t == nil {
+		return
+	}
+	text := strings.TrimSpace(t.Origin)
+	if text == "" || strings.Contains(text, "\n") {
+		return
+	}
+	c.tokens = append(c.tokens, semanticToken{
+		line:      c.firstLine + t.Position.Line - 1,
+		char:      t.Position.Column - 1,
+		length:    len(text),
+		tokenType: tokenType,
+		modifiers: modifiers,
+	})
+}
+
+func isDeprecatedSchema(schema gjson.Result) bool {
+	return schema.Get("deprecated").Bool() || deprecatedPattern.MatchString(schema.Get("description").String())
+}
+
+func isReferenceObject(key string) bool {
+	return strings.HasSuffix(key, "Ref") || slices.Contains(referenceObjects, key)
+}
+
+// Each token is 5 numbers, the line and the character relative to the previous token, the length, the type
+// and the modifiers
+func encodeSemanticTokens(tokens []semanticToken) []uint32 {
+	slices.SortFunc(tokens, func(a, b semanticToken) int {
+		if a.line != b.line {
+			return a.line - b.line
+		}
+		return a.char - b.char
+	})
+	data := []uint32{}
+	var line, char int
+	for _, t := range tokens {
+		if t.line != line {
+			char = 0
+		}
+		data = append(data, uint32(t.line-line), uint32(t.char-char), uint32(t.length), uint32(t.tokenType), uint32(t.modifiers))
+		line, char = t.line, t.char
+	}
+	return data
+}
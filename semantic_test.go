@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestSemanticTokens(t *testing.T) {
+	useTestSchemas(t, map[string][]byte{"Thing_example.com_v1.json": []byte(`{
+  "type": "object",
+  "properties": {
+    "apiVersion": {"type": "string"},
+    "kind": {"type": "string"},
+    "metadata": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "labels": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    },
+    "spec": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["image"],
+      "properties": {
+        "image": {"type": "string"},
+        "oldImage": {"type": "string", "description": "Deprecated: Use image instead."},
+        "restartPolicy": {"type": "string", "enum": ["Always", "Never"]},
+        "serviceAccountName": {"type": "string"},
+        "configRef": {"type": "object", "properties": {"name": {"type": "string"}}}
+      }
+    }
+  }
+}`)})
+
+	file := `apiVersion: example.com/v1
+kind: Thing
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  image: nginx
+  oldImage: nginx
+  restartPolicy: Always
+  serviceAccountName: web
+  configRef:
+    name: config
+  unknown: true
+---
+kind: Other
+`
+	var actual []string
+	data := semanticTokens(file)
+	var line, char int
+	for i := 0; i+4 < len(data); i += 5 {
+		if data[i] > 0 {
+			char = 0
+		}
+		line, char = line+int(data[i]), char+int(data[i+1])
+		actual = append(actual, fmt.Sprintf("%d:%d %d %s %d", line, char, data[i+2], semanticTokensLegend.TokenTypes[data[i+3]], data[i+4]))
+	}
+	expected := []string{
+		"0:0 10 property 0",
+		"1:0 4 property 0",
+		"2:0 8 property 0",
+		"3:2 4 property 0",
+		"4:2 6 property 0",
+		"6:0 4 property 0",
+		"7:2 5 property 2",
+		"8:2 8 property 1",
+		"9:2 13 property 0",
+		"9:17 6 enumMember 0",
+		"10:2 18 property 0",
+		"10:22 3 class 0",
+		"11:2 9 property 0",
+		"12:4 4 property 0",
+		"12:10 6 class 0",
+		"13:2 7 variable 0",
+	}
+	if !slices.Equal(actual, expected) {
+		t.Fatalf("expected\n%v\ngot\n%v", expected, actual)
+	}
+}
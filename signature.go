@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"go.lsp.dev/protocol"
+)
+
+// The protocol package only has parameter labels that are substrings of the signature, which is
+// ambiguous when a property is a part of another one, like `name` in `namespace`. Offsets are used
+// instead.
+type SignatureHelp struct {
+	Signatures      []SignatureInformation `json:"signatures"`
+	ActiveSignature uint32                 `json:"activeSignature"`
+	ActiveParameter uint32                 `json:"activeParameter"`
+}
+
+type SignatureInformation struct {
+	Label         string                 `json:"label"`
+	Documentation protocol.MarkupContent `json:"documentation"`
+	Parameters    []ParameterInformation `json:"parameters"`
+}
+
+type ParameterInformation struct {
+	Label         [2]uint32              `json:"label"`
+	Documentation protocol.MarkupContent `json:"documentation"`
+}
+
+// Describe the object that the cursor is in, like the signature of a function. The properties are the
+// parameters, the one on the cursor line is active, and required properties that are missing are marked
+// with `*`. The document doesn't have to be valid yaml, the object is found from the indentation.
+func signatureHelp(file string, line, char int) (SignatureHelp, bool) {
+	lines := strings.Split(file, "\n")
+	var docLines []string
+	for _, doc := range documentsInFile(file) {
+		end := doc.end
+		if end == len(lines)-1 {
+			// The empty line after the last newline, where the next key is typed
+			end++
+		}
+		if doc.start <= line && line < end {
+			docLines, line = lines[doc.start:end], line-doc.start
+			break
+		}
+	}
+	if docLines == nil {
+		return SignatureHelp{}, false
+	}
+	kind, apiVersion := documentKindAndApiVersion(strings.Join(docLines, "\n"))
+	group, version, found := strings.Cut(apiVersion, "/")
+	if !found {
+		group, version = "", group
+	}
+	if kind == "" || version == "" {
+		return SignatureHelp{}, false
+	}
+	schemaBytes, err := readSchema(gvkToSchemaId(group, version, kind) + ".json")
+	if err != nil {
+		return SignatureHelp{}, false
+	}
+
+	column := char
+	if strings.TrimSpace(docLines[line]) != "" {
+		column = yamlIndent(docLines[line])
+	}
+	segments := mappingPathAt(docLines, line, column)
+	schema := schemaAtDocumentPath(schemaBytes, "."+strings.Join(segments, "."))
+	if !schema.Get("properties").IsObject() {
+		return SignatureHelp{}, false
+	}
+	present := mappingKeysAt(docLines, line, column)
+	var required []string
+	for _, r := range schema.Get("required").Array() {
+		required = append(required, r.String())
+	}
+
+	// `containers[]` for an entry in `containers`
+	title := kind
+	if n := len(segments); n > 0 {
+		title = segments[n-1]
+		if _, err := strconv.Atoi(title); err == nil && n > 1 {
+			title = segments[n-2] + "[]"
+		}
+	}
+	label := title + " {"
+	var names []string
+	var parameters []ParameterInformation
+	var missing []string
+	schema.Get("properties").ForEach(func(key, property gjson.Result) bool {
+		name := key.String()
+		if len(parameters) > 0 {
+			label += ", "
+		}
+		start := len(label)
+		label += name
+		if slices.Contains(required, name) && !slices.Contains(present, name) {
+			label += "*"
+			missing = append(missing, "`"+name+"`")
+		}
+		names = append(names, name)
+		schemaMap, _ := property.Value().(map[string]any)
+		parameters = append(parameters, ParameterInformation{
+			Label: [2]uint32{uint32(start), uint32(len(label))},
+			Documentation: protocol.MarkupContent{
+				Kind:  protocol.Markdown,
+				Value: fmt.Sprintf("`%s` %s", schemaTypeName(schemaMap), property.Get("description").String()),
+			},
+		})
+		return true
+	})
+	label += "}"
+	documentation := schema.Get("description").String()
+	if len(missing) > 0 {
+		documentation = strings.TrimSpace(documentation + "\n\nMissing required properties: " + strings.Join(missing, ", "))
+	}
+	// The key on the cursor line, or the first one that starts with what is being typed. Nothing is
+	// highlighted if the index is out of range.
+	current := mappingKey(docLines[line], column)
+	active := slices.Index(names, current)
+	if active == -1 {
+		active = slices.IndexFunc(names, func(name string) bool { return current != "" && strings.HasPrefix(name, current) })
+	}
+	if active == -1 {
+		active = len(parameters)
+	}
+	return SignatureHelp{
+		Signatures: []SignatureInformation{{
+			Label:         label,
+			Documentation: protocol.MarkupContent{Kind: protocol.Markdown, Value: documentation},
+			Parameters:    parameters,
+		}},
+		ActiveParameter: uint32(active),
+	}, true
+}
+
+// Return the path to the mapping with keys at `column`, that `line` is in, e.g. `[spec ports 0]`. Lines
+// above with less indentation open the mapping, and `- ` makes it an entry in a sequence.
+func mappingPathAt(lines []string, line, column int) []string {
+	if content := lines[line]; strings.TrimSpace(content) != "" && yamlSpaces(content) < column && column <= len(content) {
+		if dash := strings.LastIndex(content[:column], "-"); dash != -1 {
+			return sequenceEntryPath(lines, line, dash)
+		}
+	}
+	for i := line - 1; i >= 0; i-- {
+		if isBlankOrComment(lines[i]) {
+			continue
+		}
+		keyColumn, first := yamlIndent(lines[i]), yamlSpaces(lines[i])
+		if keyColumn == column && first < column {
+			// The first key of the entry, `- name: a`
+			return sequenceEntryPath(lines, i, strings.LastIndex(lines[i][:column], "-"))
+		}
+		if keyColumn < column {
+			return append(mappingPathAt(lines, i, keyColumn), mappingKey(lines[i], keyColumn))
+		}
+	}
+	return nil
+}
+
+// Return the path to the entry with `-` at `dash` on `line`
+func sequenceEntryPath(lines []string, line, dash int) []string {
+	index := 0
+	for i := line - 1; i >= 0; i-- {
+		if isBlankOrComment(lines[i]) {
+			continue
+		}
+		first := yamlSpaces(lines[i])
+		if first > dash {
+			continue
+		}
+		if first == dash && strings.HasPrefix(lines[i][first:], "-") {
+			index++
+			continue
+		}
+		// The key of the sequence, sequences can be indented under their key or not
+		keyColumn := yamlIndent(lines[i])
+		return append(mappingPathAt(lines, i, keyColumn), mappingKey(lines[i], keyColumn), strconv.Itoa(index))
+	}
+	return []string{strconv.Itoa(index)}
+}
+
+// Return the keys in the mapping with keys at `column` that `line` is in
+func mappingKeysAt(lines []string, line, column int) []string {
+	var keys []string
+	for i := line; i >= 0; i-- {
+		if isBlankOrComment(lines[i]) {
+			continue
+		}
+		keyColumn, first := yamlIndent(lines[i]), yamlSpaces(lines[i])
+		if keyColumn == column {
+			keys = append(keys, mappingKey(lines[i], column))
+		}
+		if first < column {
+			break
+		}
+	}
+	for i := line + 1; i < len(lines); i++ {
+		if isBlankOrComment(lines[i]) {
+			continue
+		}
+		if yamlSpaces(lines[i]) < column {
+			break
+		}
+		if yamlIndent(lines[i]) == column {
+			keys = append(keys, mappingKey(lines[i], column))
+		}
+	}
+	return keys
+}
+
+// Return the key that starts at `column`, or the text that is being typed if there is no `:`
+func mappingKey(line string, column int) string {
+	if column >= len(line) {
+		return ""
+	}
+	key, _, _ := strings.Cut(line[column:], ":")
+	return strings.Trim(strings.TrimSpace(key), `"'`)
+}
+
+func isBlankOrComment(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMappingPathAt(t *testing.T) {
+	doc := `spec:
+  replicas: 1
+
+  containers:
+  - name: a
+    ports:
+      - port: 80
+
+      - port: 443
+        protocol: TCP
+  - name: b
+    image: nginx
+`
+	tests := map[string]struct {
+		line, column int
+		expected     string
+	}{
+		"root":                {line: 0, column: 0, expected: ""},
+		"key":                 {line: 1, column: 2, expected: "spec"},
+		"blank-line":          {line: 2, column: 2, expected: "spec"},
+		"first-key-of-entry":  {line: 4, column: 4, expected: "spec.containers.0"},
+		"key-in-entry":        {line: 5, column: 4, expected: "spec.containers.0"},
+		"indented-sequence":   {line: 6, column: 8, expected: "spec.containers.0.ports.0"},
+		"blank-in-sequence":   {line: 7, column: 8, expected: "spec.containers.0.ports.0"},
+		"second-entry":        {line: 9, column: 8, expected: "spec.containers.0.ports.1"},
+		"second-outer-entry":  {line: 11, column: 4, expected: "spec.containers.1"},
+		"dedented-blank-line": {line: 12, column: 2, expected: "spec"},
+	}
+	lines := strings.Split(doc, "\n")
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if actual := strings.Join(mappingPathAt(lines, test.line, test.column), "."); actual != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSignatureHelp(t *testing.T) {
+	useTestSchemas(t, map[string][]byte{"Thing_example.com_v1.json": quickFixSchema})
+
+	tests := map[string]struct {
+		doc          string
+		line, char   int
+		label        string
+		active       string
+		missingFound bool
+	}{
+		"missing-required": {
+			doc:          "apiVersion: example.com/v1\nkind: Thing\nspec:\n  replicas: 1\n  \n",
+			line:         4,
+			char:         2,
+			label:        "spec {image*, replicas, paused, version, restartPolicy, ports}",
+			missingFound: true,
+		},
+		"typing": {
+			doc:    "apiVersion: example.com/v1\nkind: Thing\nspec:\n  image: nginx\n  rest\n",
+			line:   4,
+			char:   6,
+			label:  "spec {image, replicas, paused, version, restartPolicy, ports}",
+			active: "restartPolicy",
+		},
+		"sequence-entry": {
+			doc:    "apiVersion: example.com/v1\nkind: Thing\nspec:\n  image: nginx\n  ports:\n  - protocol: TCP\n",
+			line:   5,
+			char:   6,
+			label:  "ports[] {containerPort, protocol}",
+			active: "protocol",
+		},
+		"root": {
+			doc:    "apiVersion: example.com/v1\nkind: Thing\n",
+			line:   1,
+			char:   2,
+			label:  "Thing {apiVersion, kind, spec}",
+			active: "kind",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			help, found := signatureHelp(test.doc, test.line, test.char)
+			if !found {
+				t.Fatalf("expected signature help")
+			}
+			signature := help.Signatures[0]
+			if signature.Label != test.label {
+				t.Fatalf("expected label %s, got %s", test.label, signature.Label)
+			}
+			var active string
+			if i := int(help.ActiveParameter); i < len(signature.Parameters) {
+				label := signature.Parameters[i].Label
+				active = signature.Label[label[0]:label[1]]
+			}
+			if active != test.active {
+				t.Fatalf("expected %s to be active, got %s", test.active, active)
+			}
+			if strings.Contains(signature.Documentation.Value, "Missing required properties: `image`") != test.missingFound {
+				t.Fatalf("unexpected documentation %s", signature.Documentation.Value)
+			}
+		})
+	}
+	if _, found := signatureHelp("kind: Unknown\n", 0, 0); found {
+		t.Fatalf("expected no signature help without a schema")
+	}
+}
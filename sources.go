@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// A catalog of schemas that `yamlls refresh` downloads from. When several sources provide a schema for
+// the same kind, group and version, the schema from the source listed first is used.
+type SchemaSource struct {
+	Kind SourceKind `yaml:"kind"`
+	// A http(s) or file:// url, or a path. `{version}` is replaced with the kubernetes version, e.g.
+	// `v1.29.0` or `master`.
+	Url string `yaml:"url"`
+}
+
+type SourceKind string
+
+const (
+	// Like github.com/yannh/kubernetes-json-schema, schemas are listed in `_definitions.json`
+	SOURCE_KIND_DEFINITIONS SourceKind = "definitions"
+	// Like github.com/datreeio/CRDs-catalog, schemas are listed in `index.yaml`
+	SOURCE_KIND_INDEX SourceKind = "index"
+	// A local directory with schemas named `<kind>_<group>_<version>.json`
+	SOURCE_KIND_DIRECTORY SourceKind = "directory"
+)
+
+var defaultSchemaSources = []SchemaSource{
+	{Kind: SOURCE_KIND_DEFINITIONS, Url: NATIVE_SCHEMAS_REPO_URL + "/{version}-standalone-strict"},
+	{Kind: SOURCE_KIND_INDEX, Url: CUSTOM_SCHEMAS_BASE_URL},
+}
+
+const SOURCES_FILENAME = "sources.yaml"
+
+// Read the sources from `filename`. Return the default sources if `filename` is empty and the sources
+// file in the config dir doesn't exist.
+func readSchemaSources(filename string) ([]SchemaSource, error) {
+	if filename == "" {
+		filename = filepath.Join(CONFIG_DIR, SOURCES_FILENAME)
+		if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
+			return defaultSchemaSources, nil
+		}
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read sources: %s", err)
+	}
+	var config struct {
+		Sources []SchemaSource `yaml:"sources"`
+	}
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("unmarshal sources in %s: %s", filename, err)
+	}
+	for _, s := range config.Sources {
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("invalid source in %s: %s", filename, err)
+		}
+	}
+	return config.Sources, nil
+}
+
+// Parse a source given on the command line as `<kind>=<url>`
+func parseSchemaSource(s string) (SchemaSource, error) {
+	kind, url, found := strings.Cut(s, "=")
+	if !found {
+		return SchemaSource{}, fmt.Errorf("expected `<kind>=<url>`, got `%s`", s)
+	}
+	source := SchemaSource{Kind: SourceKind(kind), Url: url}
+	return source, source.validate()
+}
+
+func (s SchemaSource) validate() error {
+	switch s.Kind {
+	case SOURCE_KIND_DEFINITIONS, SOURCE_KIND_INDEX:
+	case SOURCE_KIND_DIRECTORY:
+		if isRemoteUrl(s.Url) {
+			return fmt.Errorf("a directory source must be local, got %s", s.Url)
+		}
+	default:
+		return fmt.Errorf("unknown source kind `%s`, expected one of %s, %s and %s", s.Kind, SOURCE_KIND_DEFINITIONS, SOURCE_KIND_INDEX, SOURCE_KIND_DIRECTORY)
+	}
+	if s.Url == "" {
+		return errors.New("missing url")
+	}
+	return nil
+}
+
+func isRemoteUrl(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+func (s SchemaSource) baseUrl(k8sVersion string) string {
+	if k8sVersion != DEFAULT_K8S_VERSION {
+		k8sVersion = "v" + k8sVersion
+	}
+	return strings.TrimSuffix(strings.ReplaceAll(s.Url, "{version}", k8sVersion), "/")
+}
+
+type Definition struct{ url, basename string }
+
+// Return the schemas that the source provides
+func (s SchemaSource) definitions(k8sVersion string) ([]Definition, error) {
+	baseUrl := s.baseUrl(k8sVersion)
+	var result []Definition
+	switch s.Kind {
+	case SOURCE_KIND_DEFINITIONS:
+		body, err := fetch(fmt.Sprintf("%s/_definitions.json", baseUrl))
+		if err != nil {
+			return nil, fmt.Errorf("get definitions: %s", err)
+		}
+		var definitions struct {
+			Definitions map[string]struct {
+				GroupVersionKind []struct {
+					Group   string `json:"group"`
+					Kind    string `json:"kind"`
+					Version string `json:"version"`
+				} `json:"x-kubernetes-group-version-kind"`
+			} `json:"definitions"`
+		}
+		if err := json.Unmarshal(body, &definitions); err != nil {
+			return nil, fmt.Errorf("unmarshal definitions: %s", err)
+		}
+		for id, definition := range definitions.Definitions {
+			if strings.Contains(id, "apimachinery") || strings.Contains(id, "apiextensions") || strings.Contains(id, "apiserverinternal") || len(definition.GroupVersionKind) != 1 {
+				continue
+			}
+			gvk := definition.GroupVersionKind[0]
+			group := gvk.Group
+			groupFirstPart := strings.Split(gvk.Group, ".")[0]
+			schemaId := gvkToSchemaId(group, gvk.Version, gvk.Kind)
+			// NOTE: We want the group in schema id to be the full group, e.g. `networking.k8s.io`
+			//       But the group in the filename in the git repo is just `networking`
+			baseName := strings.Replace(schemaId, group, groupFirstPart, 1) + ".json"
+			baseName = strings.ReplaceAll(baseName, "_", "-")
+			schemaUrl := fmt.Sprintf("%s/%s", baseUrl, strings.ToLower(baseName))
+			result = append(result, Definition{url: schemaUrl, basename: schemaId + ".json"})
+		}
+	case SOURCE_KIND_INDEX:
+		body, err := fetch(fmt.Sprintf("%s/index.yaml", baseUrl))
+		if err != nil {
+			return nil, fmt.Errorf("get index: %s", err)
+		}
+		var index map[string][]struct {
+			ApiVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+			Filename   string `yaml:"filename"`
+		}
+		if err := yaml.Unmarshal(body, &index); err != nil {
+			return nil, fmt.Errorf("unmarshal index: %s", err)
+		}
+		for _, definitions := range index {
+			for _, d := range definitions {
+				if strings.Contains(d.Kind, "/") {
+					fmt.Fprintf(os.Stderr, "kind `%s` contains a `/`, it shouldn't\n", d.Kind)
+					continue
+				}
+				schemaUrl := fmt.Sprintf("%s/%s", baseUrl, d.Filename)
+				split := strings.Split(d.ApiVersion, "/")
+				if len(split) != 2 {
+					fmt.Fprintf(os.Stderr, "expected apiVersion to have exactly one `/`, got %s\n", d.ApiVersion)
+					continue
+				}
+				group, version := split[0], split[1]
+				schemaId := gvkToSchemaId(group, version, d.Kind)
+				result = append(result, Definition{url: schemaUrl, basename: schemaId + ".json"})
+			}
+		}
+	case SOURCE_KIND_DIRECTORY:
+		dir := strings.TrimPrefix(baseUrl, "file://")
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("read directory: %s", err)
+		}
+		for _, f := range files {
+			if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+				continue
+			}
+			if parts := strings.Split(strings.TrimSuffix(f.Name(), ".json"), "_"); len(parts) < 2 || len(parts) > 3 {
+				fmt.Fprintf(os.Stderr, "expected `%s` to be named `<kind>_<group>_<version>.json`\n", f.Name())
+				continue
+			}
+			result = append(result, Definition{url: "file://" + filepath.Join(dir, f.Name()), basename: f.Name()})
+		}
+	}
+	return result, nil
+}
+
+// Get the contents of a http(s) url, a file:// url or a path
+func fetch(url string) ([]byte, error) {
+	if isRemoteUrl(url) {
+		return httpGet(url)
+	}
+	return os.ReadFile(strings.TrimPrefix(url, "file://"))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSchemaSources(t *testing.T) {
+	tests := map[string]struct {
+		contents string
+		sources  []SchemaSource
+		err      bool
+	}{
+		"all-kinds": {
+			contents: `sources:
+  - kind: directory
+    url: file:///home/me/schemas
+  - kind: definitions
+    url: https://mirror.example.com/kubernetes-json-schema/{version}-standalone-strict
+  - kind: index
+    url: https://mirror.example.com/CRDs-catalog
+`,
+			sources: []SchemaSource{
+				{Kind: SOURCE_KIND_DIRECTORY, Url: "file:///home/me/schemas"},
+				{Kind: SOURCE_KIND_DEFINITIONS, Url: "https://mirror.example.com/kubernetes-json-schema/{version}-standalone-strict"},
+				{Kind: SOURCE_KIND_INDEX, Url: "https://mirror.example.com/CRDs-catalog"},
+			},
+		},
+		"unknown-kind": {
+			contents: `sources:
+  - kind: schemastore
+    url: https://www.schemastore.org
+`,
+			err: true,
+		},
+		"remote-directory": {
+			contents: `sources:
+  - kind: directory
+    url: https://example.com/schemas
+`,
+			err: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), SOURCES_FILENAME)
+			if err := os.WriteFile(filename, []byte(test.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+			sources, err := readSchemaSources(filename)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", sources)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(sources) != len(test.sources) {
+				t.Fatalf("expected %v, got %v", test.sources, sources)
+			}
+			for i := range sources {
+				if sources[i] != test.sources[i] {
+					t.Fatalf("expected %v, got %v", test.sources[i], sources[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSourcePrecedence(t *testing.T) {
+	dbDir := DB_DIR
+	DB_DIR = t.TempDir()
+	t.Cleanup(func() { DB_DIR = dbDir })
+	company, upstream := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		filepath.Join(company, "Application_argoproj.io_v1alpha1.json"):  `{"description":"company"}`,
+		filepath.Join(company, "README.md"):                              `not a schema`,
+		filepath.Join(upstream, "Application_argoproj.io_v1alpha1.json"): `{"description":"upstream"}`,
+		filepath.Join(upstream, "Service_v1.json"):                       `{"description":"upstream"}`,
+	}
+	for filename, contents := range files {
+		if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source, err := parseSchemaSource("directory=file://" + company)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sources := []SchemaSource{source, {Kind: SOURCE_KIND_DIRECTORY, Url: upstream}}
+	summary, err := refreshSchemas(sources)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if summary.Downloaded != 2 {
+		t.Fatalf("expected 2 schemas, got %+v", summary)
+	}
+	expected := map[string]string{
+		"Application_argoproj.io_v1alpha1.json": `{"description":"company"}`,
+		"Service_v1.json":                       `{"description":"upstream"}`,
+	}
+	for basename, contents := range expected {
+		actual, err := os.ReadFile(filepath.Join(schemaDir(), basename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != contents {
+			t.Fatalf("expected %s to be `%s`, got `%s`", basename, contents, actual)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const STDIN_FILENAME = "-"
+
+var defaultIncludeGlobs = []string{"*.yaml", "*.yml"}
+
+// Return the files to validate. Directories are walked recursively, skipping hidden directories, and the
+// files in them are included if their name matches one of `include`. Files and directories matching one of
+// `exclude` are skipped, the globs are matched against both the name and the path.
+func collectFiles(paths, include, exclude []string) ([]string, error) {
+	if len(include) == 0 {
+		include = defaultIncludeGlobs
+	}
+	var files []string
+	for _, path := range paths {
+		if path == STDIN_FILENAME {
+			files = append(files, path)
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if !matchesGlob(exclude, path) {
+				files = append(files, path)
+			}
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p != path && matchesGlob(exclude, p) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if p != path && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if matchesGlob(include, p) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walk %s: %s", path, err)
+		}
+	}
+	// A file can be given both on its own and in a directory
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
+
+func matchesGlob(globs []string, path string) bool {
+	for _, glob := range globs {
+		if matched, _ := filepath.Match(glob, filepath.Base(path)); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(glob, filepath.ToSlash(path)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate `files` with `jobs` workers, the results are in the same order as `files`. Custom resource
+// definitions in the files are used to validate the other files, and resources defined in more than one of
+// the files are reported, like in the language server. A file that can't be read or validated gets a
+// `validation_failed` error, the other files are still validated.
+func validateFiles(files []string, stdin io.Reader, helm bool, jobs int) []FileErrors {
+	contents := make([]string, len(files))
+	results := make([]FileErrors, len(files))
+	for i, file := range files {
+		results[i].Filename = file
+		var b []byte
+		var err error
+		if file == STDIN_FILENAME {
+			b, err = io.ReadAll(stdin)
+		} else {
+			b, err = os.ReadFile(file)
+		}
+		if err != nil {
+			results[i].Errors = []ValidationError{validationFailedError(fmt.Sprintf("read file: %s", err))}
+			continue
+		}
+		contents[i] = string(b)
+		workspace.indexFile(indexFilename(file), contents[i])
+	}
+	defer func() {
+		for _, file := range files {
+			workspace.indexFile(indexFilename(file), "")
+		}
+	}()
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range max(jobs, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				file := files[i]
+				validate := fileValidate
+				if helm || file != STDIN_FILENAME && isHelmTemplate(file) {
+					validate = fileValidateTemplate
+				}
+				errors, fail := validate(contents[i])
+				if fail != VALIDATION_FAILURE_REASON_NOT_A_FAILURE {
+					results[i].Errors = []ValidationError{validationFailedError(string(fail))}
+					continue
+				}
+				results[i].Errors = append(errors, workspace.duplicateResources(indexFilename(file))...)
+			}
+		}()
+	}
+	for i := range files {
+		if results[i].Errors == nil {
+			indexes <- i
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// An error for the whole file, it is always an error so that the exit code shows that validation failed
+func validationFailedError(reason string) ValidationError {
+	return ValidationError{
+		Range:    newRange(0, 0, 0, 0),
+		Message:  fmt.Sprintf("could not validate the file: %s", reason),
+		Type:     "validation_failed",
+		Severity: SEVERITY_ERROR,
+	}
+}
+
+// The workspace index is keyed by absolute filenames, so that duplicate resources are reported relative
+// to the file they are reported in
+func indexFilename(file string) string {
+	if file == STDIN_FILENAME {
+		return file
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+	return abs
+}
+
+func validationSummary(results []FileErrors) string {
+	var errors, warnings, filesWithErrors int
+	for _, result := range results {
+		if len(result.Errors) > 0 {
+			filesWithErrors++
+		}
+		for _, e := range result.Errors {
+			if e.Severity == SEVERITY_WARN {
+				warnings++
+			} else {
+				errors++
+			}
+		}
+	}
+	return fmt.Sprintf("validated %d %s: %d %s and %d %s in %d %s",
+		len(results), plural(len(results), "file"),
+		errors, plural(errors, "error"),
+		warnings, plural(warnings, "warning"),
+		filesWithErrors, plural(filesWithErrors, "file"),
+	)
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCollectFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.yaml", "b.yml", "c.json", "sub/d.yaml", "sub/e.yaml", ".git/f.yaml", "vendor/g.yaml"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := map[string]struct {
+		paths, include, exclude []string
+		expected                []string
+	}{
+		"directory": {
+			paths:    []string{dir},
+			expected: []string{"a.yaml", "b.yml", "sub/d.yaml", "sub/e.yaml", "vendor/g.yaml"},
+		},
+		"include": {
+			paths:    []string{dir},
+			include:  []string{"*.json"},
+			expected: []string{"c.json"},
+		},
+		"exclude-file-and-directory": {
+			paths:    []string{dir},
+			exclude:  []string{"e.yaml", "vendor"},
+			expected: []string{"a.yaml", "b.yml", "sub/d.yaml"},
+		},
+		"file-not-matching-include": {
+			paths:    []string{filepath.Join(dir, "c.json"), STDIN_FILENAME},
+			expected: []string{STDIN_FILENAME, "c.json"},
+		},
+		"file-in-directory": {
+			paths:    []string{filepath.Join(dir, "sub", "e.yaml"), filepath.Join(dir, "sub")},
+			expected: []string{"sub/d.yaml", "sub/e.yaml"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, err := collectFiles(test.paths, test.include, test.exclude)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var relative []string
+			for _, f := range files {
+				if f == STDIN_FILENAME {
+					relative = append(relative, f)
+					continue
+				}
+				r, _ := filepath.Rel(dir, f)
+				relative = append(relative, filepath.ToSlash(r))
+			}
+			if !slices.Equal(relative, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, relative)
+			}
+		})
+	}
+	if _, err := collectFiles([]string{filepath.Join(dir, "missing.yaml")}, nil, nil); err == nil {
+		t.Fatalf("expected an error for a file that doesn't exist")
+	}
+}
+
+func TestValidateFiles(t *testing.T) {
+	schemaCache["Service_v1.json"] = serviceV1
+	t.Cleanup(func() { delete(schemaCache, "Service_v1.json") })
+	dir := t.TempDir()
+	service := func(name string) string {
+		return "apiVersion: v1\nkind: Service\nmetadata:\n  name: " + name + "\n"
+	}
+	var files []string
+	for i, contents := range []string{service("web"), service("api") + "spec:\n  replica: 1\n", service("web")} {
+		file := filepath.Join(dir, []string{"a.yaml", "b.yaml", "c.yaml"}[i])
+		if err := os.WriteFile(file, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	files = append(files, STDIN_FILENAME, filepath.Join(dir, "missing.yaml"))
+
+	results := validateFiles(files, strings.NewReader(service("db")), false, 3)
+	if len(results) != 5 {
+		t.Fatalf("expected a result per file, got %v", results)
+	}
+	for i, result := range results {
+		if result.Filename != files[i] {
+			t.Fatalf("expected the results in the same order as the files, got %s at %d", result.Filename, i)
+		}
+	}
+	expectedTypes := [][]string{{"duplicate_resource"}, {"additional_property_not_allowed"}, {"duplicate_resource"}, nil, {"validation_failed"}}
+	for i, result := range results {
+		var types []string
+		for _, e := range result.Errors {
+			types = append(types, e.Type)
+		}
+		if !slices.Equal(types, expectedTypes[i]) {
+			t.Fatalf("expected %v for %s, got %v", expectedTypes[i], result.Filename, result.Errors)
+		}
+	}
+	if summary := validationSummary(results); summary != "validated 5 files: 2 errors and 2 warnings in 4 files" {
+		t.Fatalf("unexpected summary `%s`", summary)
+	}
+	if errors := workspace.duplicateResources(indexFilename(files[0])); len(errors) != 0 {
+		t.Fatalf("expected the files to be removed from the workspace index afterwards, got %v", errors)
+	}
+}
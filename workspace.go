@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// The workspace index keeps track of what is defined in the yaml files in the workspace, both the files
+// that are open and the files on disk
+type WorkspaceIndex struct {
+	mu sync.RWMutex
+	// filename -> basename -> schema, generated from CustomResourceDefinitions
+	schemas map[string]map[string][]byte
+	// filename -> resources defined in the file, to find resources that are defined more than once
+	resources map[string][]Resource
+}
+
+var workspace = &WorkspaceIndex{schemas: map[string]map[string][]byte{}, resources: map[string][]Resource{}}
+
+const MAX_INDEXED_FILE_SIZE = 1 << 20
+
+// Index all yaml files under `root`, skipping hidden directories
+func (w *WorkspaceIndex) indexDir(root string) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules" || d.Name() == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > MAX_INDEXED_FILE_SIZE {
+			return nil
+		}
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		w.indexFile(path, string(contents))
+		return nil
+	})
+}
+
+// Update the index with the contents of `filename`. Return true if the schemas or resources changed.
+func (w *WorkspaceIndex) indexFile(filename, contents string) bool {
+	schemas := map[string][]byte{}
+	for _, doc := range documentsInFile(contents) {
+		if !strings.Contains(doc.document, "CustomResourceDefinition") {
+			continue
+		}
+		gvk, ok := documentGVK(doc.document)
+		if !ok || gvk.kind != "CustomResourceDefinition" || gvk.group != "apiextensions.k8s.io" {
+			continue
+		}
+		docSchemas, err := crdSchemas(doc.document)
+		if err != nil {
+			if logger != nil {
+				logger.Info("generate schemas from custom resource definition", "filename", filename, "err", err)
+			}
+			continue
+		}
+		maps.Copy(schemas, docSchemas)
+	}
+	var resources []Resource
+	if !isHelmTemplate(filename) && !isKustomization(filename) {
+		resources = fileResources(contents)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	previousSchemas, previousResources := w.schemas[filename], w.resources[filename]
+	if len(schemas) == 0 {
+		delete(w.schemas, filename)
+	} else {
+		w.schemas[filename] = schemas
+	}
+	if len(resources) == 0 {
+		delete(w.resources, filename)
+	} else {
+		w.resources[filename] = resources
+	}
+	return !maps.EqualFunc(previousSchemas, schemas, bytes.Equal) || !slices.Equal(previousResources, resources)
+}
+
+// Return the schema for `basename` from the CustomResourceDefinitions in the workspace. If several files
+// define it, the first filename in sorted order is used so that the same one is always picked.
+func (w *WorkspaceIndex) schema(basename string) ([]byte, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, filename := range slices.Sorted(maps.Keys(w.schemas)) {
+		if schema, found := w.schemas[filename][basename]; found {
+			return schema, true
+		}
+	}
+	return nil, false
+}
+
+func (w *WorkspaceIndex) schemaIds() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var ids []string
+	for _, schemas := range w.schemas {
+		for basename := range schemas {
+			ids = append(ids, basename)
+		}
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// Return a warning for each resource in `filename` that is also defined in another file in the same
+// directory tree. Files in sibling directories, such as `clusters/prod` and `clusters/staging`, often
+// define the same resources for different clusters.
+func (w *WorkspaceIndex) duplicateResources(filename string) []ValidationError {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var errors []ValidationError
+	for _, resource := range w.resources[filename] {
+		var others []string
+		for _, otherFilename := range slices.Sorted(maps.Keys(w.resources)) {
+			if otherFilename == filename || !inSameDirectoryTree(filename, otherFilename) {
+				continue
+			}
+			for _, other := range w.resources[otherFilename] {
+				if other.identity() == resource.identity() {
+					relative, err := filepath.Rel(filepath.Dir(filename), otherFilename)
+					if err != nil {
+						relative = otherFilename
+					}
+					others = append(others, fmt.Sprintf("%s:%d", relative, other.Range.Start.Line+1))
+				}
+			}
+		}
+		if len(others) > 0 {
+			errors = append(errors, ValidationError{
+				Range:    resource.Range,
+				Message:  fmt.Sprintf("duplicate resource %s, it is also defined in %s", resource, strings.Join(others, ", ")),
+				Type:     "duplicate_resource",
+				Severity: SEVERITY_WARN,
+			})
+		}
+	}
+	return errors
+}
+
+// Return true if the directory of one file is the directory of the other or a parent of it
+func inSameDirectoryTree(a, b string) bool {
+	within := func(dir, parent string) bool {
+		relative, err := filepath.Rel(parent, dir)
+		return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+	}
+	dirA, dirB := filepath.Dir(a), filepath.Dir(b)
+	return within(dirA, dirB) || within(dirB, dirA)
+}